@@ -37,6 +37,23 @@ func findElementWithName(slice interface{}, name string) interface{} {
 	return nil
 }
 
+func elementNames(slice interface{}) []string {
+	names := []string{}
+	for _, el := range slice.([]interface{}) {
+		names = append(names, el.(map[interface{}]interface{})["name"].(string))
+	}
+	return names
+}
+
+// withoutProvenance removes the provenance block, which depends on the
+// build and the input files, from a transformed manifest.
+func withoutProvenance(manifest map[string]interface{}) map[string]interface{} {
+	block := manifest["properties"].(map[interface{}]interface{})["ducatify"].(map[interface{}]interface{})
+	Expect(block).To(HaveKey("provenance"))
+	delete(block, "provenance")
+	return manifest
+}
+
 var _ = Describe("Manifest transformer", func() {
 	var (
 		cmd *exec.Cmd
@@ -63,6 +80,10 @@ var _ = Describe("Manifest transformer", func() {
 
 		err = candiedyaml.Unmarshal(actualBytes, &actualOutput)
 		Expect(err).NotTo(HaveOccurred())
+
+		actualOutput = withoutProvenance(actualOutput)
+		actualBytes, err = candiedyaml.Marshal(actualOutput)
+		Expect(err).NotTo(HaveOccurred())
 	})
 
 	It("generates the expected output", func() {
@@ -149,3 +170,27 @@ var _ = Describe("Manifest transformer", func() {
 		Expect(actualDucatiProps).To(Equal(expectedDucatiProps))
 	})
 })
+
+var _ = Describe("Conflict policy flags", func() {
+	transform := func(flag string) *gexec.Session {
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/does-not-exist.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			flag,
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(1))
+		return session
+	}
+
+	It("rejects an unknown garden plugin policy before reading the manifests", func() {
+		session := transform("-gardenPluginConflict=bogus")
+		Expect(string(session.Err.Contents())).To(ContainSubstring(`parsing gardenPluginConflict: unknown policy "bogus", expected warn or fail`))
+	})
+
+	It("rejects an unknown property tree policy before reading the manifests", func() {
+		session := transform("-propertyConflicts=connet=bogus")
+		Expect(string(session.Err.Contents())).To(ContainSubstring(`parsing propertyConflicts: unknown policy "bogus"`))
+	})
+})
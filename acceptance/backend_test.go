@@ -0,0 +1,89 @@
+package acceptance_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Networking backends", func() {
+	transform := func(args ...string) map[string]interface{} {
+		args = append([]string{
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+		}, args...)
+		session, err := gexec.Start(exec.Command(binPath, args...), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		var manifest map[string]interface{}
+		Expect(candiedyaml.Unmarshal(session.Out.Contents(), &manifest)).To(Succeed())
+		return manifest
+	}
+
+	It("adds flannel instead of ducati when selected", func() {
+		manifest := transform("-backend", "flannel")
+
+		Expect(elementNames(manifest["releases"])).To(ContainElement("flannel"))
+		Expect(elementNames(manifest["releases"])).NotTo(ContainElement("ducati"))
+		Expect(elementNames(manifest["jobs"])).NotTo(ContainElement("ducati_db"))
+
+		properties := manifest["properties"].(map[interface{}]interface{})
+		Expect(properties).To(HaveKey("flannel"))
+		Expect(properties).NotTo(HaveKey("ducati"))
+		garden := properties["garden"].(map[interface{}]interface{})
+		Expect(garden["network_plugin"]).To(Equal("/var/vcap/packages/flannel-cni/bin/guardian-cni-adapter"))
+	})
+
+	It("takes the backend from the config file", func() {
+		configFile, err := ioutil.TempFile("", "ducatify-config")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(configFile.Name())
+		_, err = configFile.WriteString("backend: flannel\nnsync_network_id: some-network\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configFile.Close()).To(Succeed())
+
+		manifest := transform("-config", configFile.Name())
+
+		properties := manifest["properties"].(map[interface{}]interface{})
+		Expect(properties).To(HaveKey("flannel"))
+		nsync := properties["diego"].(map[interface{}]interface{})["nsync"].(map[interface{}]interface{})
+		Expect(nsync["network_id"]).To(Equal("some-network"))
+	})
+
+	It("rejects settings in the config file that it does not know", func() {
+		configFile, err := ioutil.TempFile("", "ducatify-config")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(configFile.Name())
+		_, err = configFile.WriteString("backend: flannel\ncells:\n  include_job: [cell_z1]\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configFile.Close()).To(Succeed())
+
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			"-config", configFile.Name(),
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(string(session.Err.Contents())).To(ContainSubstring(`loading config: unknown setting "cells.include_job"`))
+	})
+
+	It("fails for an unknown backend", func() {
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			"-backend", "nope",
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(string(session.Err.Contents())).To(ContainSubstring(`unknown backend "nope"`))
+	})
+})
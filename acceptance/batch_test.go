@@ -0,0 +1,129 @@
+package acceptance_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Batch transforming environments", func() {
+	var (
+		workDir, envsDir, outDir string
+		vanillaBytes, credsBytes []byte
+		expectedOutput           map[string]interface{}
+	)
+
+	writeEnvironment := func(name string, creds []byte, overrides string) {
+		envDir := filepath.Join(envsDir, name)
+		Expect(os.MkdirAll(envDir, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(envDir, "diego.yml"), vanillaBytes, 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(envDir, "cf_creds.yml"), creds, 0644)).To(Succeed())
+		if overrides != "" {
+			Expect(ioutil.WriteFile(filepath.Join(envDir, "ducatify.yml"), []byte(overrides), 0644)).To(Succeed())
+		}
+	}
+
+	readOutput := func(name string) map[string]interface{} {
+		bytes, err := ioutil.ReadFile(filepath.Join(outDir, name+".yml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var manifest map[string]interface{}
+		Expect(candiedyaml.Unmarshal(bytes, &manifest)).To(Succeed())
+		return manifest
+	}
+
+	BeforeEach(func() {
+		var err error
+		workDir, err = ioutil.TempDir("", "ducatify-batch")
+		Expect(err).NotTo(HaveOccurred())
+		envsDir = filepath.Join(workDir, "envs")
+		outDir = filepath.Join(workDir, "out")
+		Expect(os.MkdirAll(outDir, 0755)).To(Succeed())
+
+		vanillaBytes, _ = loadFixture("skeleton_vanilla")
+		credsBytes, _ = loadFixture("cf_creds")
+		_, expectedOutput = loadFixture("skeleton_transformed")
+
+		writeEnvironment("env-a", credsBytes, "")
+		writeEnvironment("env-b", []byte("admin_user: no-api-here\n"), "")
+		writeEnvironment("env-c", credsBytes, "db_password: env-c-password\n")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(workDir)
+	})
+
+	It("transforms every environment and reports failures without stopping the others", func() {
+		cmd := exec.Command(binPath, "batch", "-out", outDir, "-workers", "2", envsDir)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(1))
+
+		Expect(session.Out).To(gbytes.Say(`env-a\s+ok`))
+		Expect(session.Out).To(gbytes.Say(`env-b\s+failed\s+getting system domain`))
+		Expect(session.Out).To(gbytes.Say(`env-c\s+ok`))
+		Expect(session.Out).To(gbytes.Say("2 succeeded, 1 failed"))
+
+		Expect(withoutProvenance(readOutput("env-a"))).To(Equal(expectedOutput))
+		Expect(filepath.Join(outDir, "env-b.yml")).NotTo(BeAnExistingFile())
+
+		connetProps := readOutput("env-c")["properties"].(map[interface{}]interface{})["connet"]
+		database := connetProps.(map[interface{}]interface{})["daemon"].(map[interface{}]interface{})["database"]
+		Expect(database).To(HaveKeyWithValue("password", "env-c-password"))
+	})
+
+	It("creates the output directory when it does not exist", func() {
+		outDir = filepath.Join(workDir, "new", "out")
+		Expect(os.RemoveAll(filepath.Join(envsDir, "env-b"))).To(Succeed())
+
+		cmd := exec.Command(binPath, "batch", "-out", outDir, envsDir)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say("2 succeeded, 0 failed"))
+		Expect(withoutProvenance(readOutput("env-a"))).To(Equal(expectedOutput))
+	})
+
+	It("fails an environment whose overrides misspell a setting", func() {
+		writeEnvironment("env-c", credsBytes, "db_pasword: env-c-password\n")
+
+		cmd := exec.Command(binPath, "batch", "-out", outDir, envsDir)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(1))
+
+		Expect(session.Out).To(gbytes.Say(`env-c\s+failed\s+unknown setting "db_pasword"`))
+		Expect(filepath.Join(outDir, "env-c.yml")).NotTo(BeAnExistingFile())
+	})
+
+	It("reads environments from a list file", func() {
+		listPath := filepath.Join(workDir, "envs.yml")
+		Expect(ioutil.WriteFile(listPath, []byte(`---
+environments:
+- name: listed
+  diego: envs/env-a/diego.yml
+  cfCreds: envs/env-a/cf_creds.yml
+  output: out/listed.yml
+  overrides:
+    connet_port: 5555
+`), 0644)).To(Succeed())
+
+		cmd := exec.Command(binPath, "batch", listPath)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say("1 succeeded, 0 failed"))
+		ccBridge := findElementWithName(readOutput("listed")["jobs"], "cc_bridge_z1")
+		routes := ccBridge.(map[interface{}]interface{})["properties"].(map[interface{}]interface{})["route_registrar"].(map[interface{}]interface{})["routes"]
+		Expect(routes.([]interface{})[0]).To(HaveKeyWithValue("port", int64(5555)))
+	})
+})
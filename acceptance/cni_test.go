@@ -0,0 +1,85 @@
+package acceptance_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("CNI config", func() {
+	var configPath string
+
+	BeforeEach(func() {
+		configFile, err := ioutil.TempFile("", "ducatify-config")
+		Expect(err).NotTo(HaveOccurred())
+		configPath = configFile.Name()
+		Expect(configFile.Close()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.Remove(configPath)
+	})
+
+	run := func(config string) *gexec.Session {
+		Expect(ioutil.WriteFile(configPath, []byte(config), 0644)).To(Succeed())
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			"-config", configPath,
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("renders the plugin chain from the config file into the ducati properties", func() {
+		session := run(`---
+cni:
+  cni_version: 0.3.1
+  name: ducati-overlay
+  mtu: 1450
+  plugins:
+  - type: bridge
+    ipam:
+      type: host-local
+      subnet: 10.255.1.0/24
+    args:
+      isGateway: true
+`)
+		Eventually(session).Should(gexec.Exit(0))
+
+		var manifest map[string]interface{}
+		Expect(candiedyaml.Unmarshal(session.Out.Contents(), &manifest)).To(Succeed())
+		ducati := manifest["properties"].(map[interface{}]interface{})["ducati"].(map[interface{}]interface{})
+		cni := ducati["cni"].(map[interface{}]interface{})
+		Expect(cni["cniVersion"]).To(Equal("0.3.1"))
+		Expect(cni["plugins"]).To(Equal([]interface{}{
+			map[interface{}]interface{}{
+				"type":      "bridge",
+				"isGateway": true,
+				"mtu":       int64(1450),
+				"ipam": map[interface{}]interface{}{
+					"type":   "host-local",
+					"subnet": "10.255.1.0/24",
+				},
+			},
+		}))
+	})
+
+	It("fails on an invalid plugin chain", func() {
+		session := run(`---
+cni:
+  cni_version: 0.3.1
+  name: ducati-overlay
+  plugins:
+  - type: /bin/bridge
+`)
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(string(session.Err.Contents())).To(ContainSubstring("checking cni config: plugin 1"))
+	})
+})
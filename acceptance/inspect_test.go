@@ -0,0 +1,67 @@
+package acceptance_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Inspecting a manifest", func() {
+	inspect := func(args ...string) *gexec.Session {
+		session, err := gexec.Start(exec.Command(binPath, append([]string{"inspect"}, args...)...), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+		return session
+	}
+
+	It("prints the networking configuration as tables", func() {
+		session := inspect("fixtures/skeleton_transformed.yml")
+
+		Expect(session.Out).To(gbytes.Say(`RELEASES\nNAME\s+VERSION\nducati\s+latest`))
+		Expect(session.Out).To(gbytes.Say(`colocated_z3\s+0\s+ducati, connet`))
+		Expect(session.Out).To(gbytes.Say(`location\s+ducati_db job, found through consul`))
+		Expect(session.Out).To(gbytes.Say(`address\s+ducati-db.service.cf.internal:5432`))
+		Expect(session.Out).To(gbytes.Say(`global\s+/var/vcap/packages/ducati/bin/guardian-cni-adapter`))
+		Expect(session.Out).To(gbytes.Say(`network_id\s+ducati-overlay`))
+		Expect(session.Out).To(gbytes.Say(`cc_bridge_z1\s+connet\s+4002\s+connet.systemdomain.mycf.example.com`))
+		Expect(string(session.Out.Contents())).NotTo(ContainSubstring("some-password"))
+	})
+
+	It("prints JSON with -json", func() {
+		session := inspect("-json", "fixtures/skeleton_transformed.yml")
+
+		var inspection map[string]interface{}
+		Expect(json.Unmarshal(session.Out.Contents(), &inspection)).To(Succeed())
+		Expect(inspection["nsync_network_id"]).To(Equal("ducati-overlay"))
+		Expect(inspection["database"]).To(HaveKeyWithValue("username", "ducati_daemon"))
+		Expect(inspection["database"]).To(HaveKeyWithValue("password_set", true))
+		Expect(inspection["database"]).NotTo(HaveKey("password"))
+	})
+
+	It("reports an untransformed manifest", func() {
+		session := inspect("fixtures/skeleton_vanilla.yml")
+		Expect(session.Out).To(gbytes.Say(`SCHEMA VERSION\s+0`))
+		Expect(session.Out).To(gbytes.Say(`DATABASE\nnone`))
+	})
+
+	It("reports a malformed schema version and still prints the summary", func() {
+		transformedBytes, _ := loadFixture("skeleton_transformed")
+		manifestFile, err := ioutil.TempFile("", "ducatify-inspect")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(manifestFile.Name())
+		_, err = manifestFile.Write(bytes.Replace(transformedBytes, []byte("schema_version: 2"), []byte("schema_version: two"), 1))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifestFile.Close()).To(Succeed())
+
+		session := inspect(manifestFile.Name())
+		Expect(session.Out).To(gbytes.Say(`SCHEMA VERSION\s+unknown \(expected properties.ducatify.schema_version to be a positive integer, got two\)`))
+		Expect(session.Out).To(gbytes.Say(`network_id\s+ducati-overlay`))
+	})
+})
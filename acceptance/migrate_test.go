@@ -0,0 +1,43 @@
+package acceptance_test
+
+import (
+	"os/exec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Migrating manifests", func() {
+	migrate := func(path string) *gexec.Session {
+		cmd := exec.Command(binPath, "-migrate", "-diego", path)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("brings a manifest transformed before schema versions up to date", func() {
+		session := migrate("fixtures/skeleton_transformed_v1.yml")
+		Eventually(session).Should(gexec.Exit(0))
+
+		expectedBytes, _ := loadFixture("skeleton_transformed")
+		Expect(session.Out.Contents()).To(MatchYAML(expectedBytes))
+		Expect(string(session.Err.Contents())).To(ContainSubstring(
+			"migrated: add overlay_network and subnet_prefix_length to properties.ducati.daemon"))
+	})
+
+	It("leaves a current manifest as it is", func() {
+		session := migrate("fixtures/skeleton_transformed.yml")
+		Eventually(session).Should(gexec.Exit(0))
+
+		expectedBytes, _ := loadFixture("skeleton_transformed")
+		Expect(session.Out.Contents()).To(MatchYAML(expectedBytes))
+		Expect(string(session.Err.Contents())).To(ContainSubstring("manifest is up to date"))
+	})
+
+	It("fails for a manifest that was never transformed", func() {
+		session := migrate("fixtures/skeleton_vanilla.yml")
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(string(session.Err.Contents())).To(ContainSubstring("has not been transformed by ducatify"))
+	})
+})
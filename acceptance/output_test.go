@@ -0,0 +1,129 @@
+package acceptance_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Writing the transformed manifest to a file", func() {
+	var (
+		workDir      string
+		manifestPath string
+		vanillaBytes []byte
+
+		expectedOutput map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		workDir, err = ioutil.TempDir("", "ducatify-output")
+		Expect(err).NotTo(HaveOccurred())
+
+		vanillaBytes, _ = loadFixture("skeleton_vanilla")
+		_, expectedOutput = loadFixture("skeleton_transformed")
+
+		manifestPath = filepath.Join(workDir, "diego.yml")
+		Expect(ioutil.WriteFile(manifestPath, vanillaBytes, 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(workDir)
+	})
+
+	run := func(args ...string) *gexec.Session {
+		args = append([]string{"-cfCreds", "fixtures/cf_creds.yml"}, args...)
+		session, err := gexec.Start(exec.Command(binPath, args...), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit())
+		return session
+	}
+
+	readManifest := func(path string) map[string]interface{} {
+		bytes, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		var manifest map[string]interface{}
+		Expect(candiedyaml.Unmarshal(bytes, &manifest)).To(Succeed())
+		return manifest
+	}
+
+	backups := func() []string {
+		matches, err := filepath.Glob(filepath.Join(workDir, "*.bak"))
+		Expect(err).NotTo(HaveOccurred())
+		return matches
+	}
+
+	It("writes to the path given with -o and leaves stdout empty", func() {
+		outputPath := filepath.Join(workDir, "out.yml")
+		session := run("-diego", manifestPath, "-o", outputPath)
+		Expect(session.ExitCode()).To(Equal(0))
+
+		Expect(session.Out.Contents()).To(BeEmpty())
+		Expect(withoutProvenance(readManifest(outputPath))).To(Equal(expectedOutput))
+		Expect(backups()).To(BeEmpty())
+	})
+
+	It("keeps a backup of an output file it replaces", func() {
+		outputPath := filepath.Join(workDir, "out.yml")
+		Expect(ioutil.WriteFile(outputPath, []byte("old: contents\n"), 0600)).To(Succeed())
+
+		session := run("-diego", manifestPath, "-o", outputPath)
+		Expect(session.ExitCode()).To(Equal(0))
+
+		Expect(withoutProvenance(readManifest(outputPath))).To(Equal(expectedOutput))
+		Expect(backups()).To(HaveLen(1))
+		Expect(ioutil.ReadFile(backups()[0])).To(Equal([]byte("old: contents\n")))
+	})
+
+	It("never overwrites an earlier backup", func() {
+		outputPath := filepath.Join(workDir, "out.yml")
+		Expect(ioutil.WriteFile(outputPath, []byte("old: contents\n"), 0600)).To(Succeed())
+
+		// backups made within the same second share a timestamp
+		now := time.Now().UTC()
+		for _, t := range []time.Time{now, now.Add(time.Second)} {
+			existing := fmt.Sprintf("%s.%s.bak", outputPath, t.Format("20060102T150405Z"))
+			Expect(ioutil.WriteFile(existing, []byte("earlier: backup\n"), 0600)).To(Succeed())
+		}
+
+		session := run("-diego", manifestPath, "-o", outputPath)
+		Expect(session.ExitCode()).To(Equal(0))
+
+		Expect(backups()).To(HaveLen(3))
+		contents := []string{}
+		for _, backup := range backups() {
+			bytes, err := ioutil.ReadFile(backup)
+			Expect(err).NotTo(HaveOccurred())
+			contents = append(contents, string(bytes))
+		}
+		Expect(contents).To(ConsistOf("earlier: backup\n", "earlier: backup\n", "old: contents\n"))
+	})
+
+	It("replaces the diego manifest with -in-place, keeping a backup and the file mode", func() {
+		session := run("-diego", manifestPath, "-in-place")
+		Expect(session.ExitCode()).To(Equal(0))
+
+		Expect(withoutProvenance(readManifest(manifestPath))).To(Equal(expectedOutput))
+		Expect(backups()).To(HaveLen(1))
+		Expect(ioutil.ReadFile(backups()[0])).To(Equal(vanillaBytes))
+
+		info, err := os.Stat(manifestPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("rejects -o together with -in-place", func() {
+		session := run("-diego", manifestPath, "-in-place", "-o", filepath.Join(workDir, "out.yml"))
+		Expect(session.ExitCode()).NotTo(Equal(0))
+		Expect(session.Err.Contents()).To(ContainSubstring("mutually exclusive"))
+	})
+})
@@ -0,0 +1,112 @@
+package acceptance_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Environment profiles", func() {
+	var profileDir string
+
+	BeforeEach(func() {
+		var err error
+		profileDir, err = ioutil.TempDir("", "ducatify-profiles")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(profileDir)
+	})
+
+	transform := func(args ...string) map[string]interface{} {
+		args = append([]string{
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			"-profileDir", profileDir,
+		}, args...)
+		session, err := gexec.Start(exec.Command(binPath, args...), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		var manifest map[string]interface{}
+		Expect(candiedyaml.Unmarshal(session.Out.Contents(), &manifest)).To(Succeed())
+		return manifest
+	}
+
+	gardenDNSServers := func(manifest map[string]interface{}) interface{} {
+		return manifest["properties"].(map[interface{}]interface{})["garden"].(map[interface{}]interface{})["dns_servers"]
+	}
+
+	It("uses the selected built-in profile", func() {
+		manifest := transform("-profile", "aws")
+		Expect(gardenDNSServers(manifest)).To(Equal([]interface{}{"169.254.169.253"}))
+	})
+
+	It("uses profiles registered in the profile directory", func() {
+		Expect(ioutil.WriteFile(filepath.Join(profileDir, "our-aws.yml"), []byte(`---
+base: aws
+garden_dns_servers: [10.0.0.2]
+db_persistent_disk: 2048
+`), 0644)).To(Succeed())
+
+		manifest := transform("-profile", "our-aws")
+		Expect(gardenDNSServers(manifest)).To(Equal([]interface{}{"10.0.0.2"}))
+
+		dbJob := findElementWithName(manifest["jobs"], "ducati_db")
+		Expect(dbJob).To(HaveKeyWithValue("persistent_disk", int64(2048)))
+	})
+
+	It("rejects a profile file that misspells a setting", func() {
+		Expect(ioutil.WriteFile(filepath.Join(profileDir, "typo.yml"), []byte(`---
+base: aws
+garden_dns_server: [10.0.0.2]
+`), 0644)).To(Succeed())
+
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			"-profileDir", profileDir,
+			"-profile", "aws",
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(string(session.Err.Contents())).To(ContainSubstring(`typo.yml: unknown setting "garden_dns_server"`))
+	})
+
+	It("fails for an unknown profile", func() {
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			"-profile", "nope",
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(string(session.Err.Contents())).To(ContainSubstring(`unknown profile "nope"`))
+	})
+
+	It("fails before reading the manifests when the settings are invalid", func() {
+		Expect(ioutil.WriteFile(filepath.Join(profileDir, "broken.yml"), []byte(`---
+garden_dns_servers: [not-an-ip]
+`), 0644)).To(Succeed())
+
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/does-not-exist.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			"-profileDir", profileDir,
+			"-profile", "broken",
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(string(session.Err.Contents())).To(ContainSubstring(`garden: dns server "not-an-ip" is not an IP address`))
+	})
+})
@@ -0,0 +1,55 @@
+package acceptance_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os/exec"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Provenance", func() {
+	fileSum := func(path string) string {
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		sum := sha256.Sum256(contents)
+		return hex.EncodeToString(sum[:])
+	}
+
+	It("records the version, input hashes and redacted settings in the manifest", func() {
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		var manifest map[string]interface{}
+		Expect(candiedyaml.Unmarshal(session.Out.Contents(), &manifest)).To(Succeed())
+		block := manifest["properties"].(map[interface{}]interface{})["ducatify"].(map[interface{}]interface{})
+		provenance := block["provenance"].(map[interface{}]interface{})
+
+		Expect(provenance).To(HaveKeyWithValue("version", "dev"))
+		Expect(provenance).To(HaveKeyWithValue("input_sha256", fileSum("fixtures/skeleton_vanilla.yml")))
+		Expect(provenance).To(HaveKeyWithValue("creds_sha256", fileSum("fixtures/cf_creds.yml")))
+		Expect(provenance["settings"]).To(HaveKeyWithValue("db_password", "<redacted>"))
+		Expect(provenance["settings"]).To(HaveKeyWithValue("db_ssl_mode", "disable"))
+		Expect(string(session.Out.Contents())).NotTo(ContainSubstring("db_password: some-password"))
+	})
+
+	It("prints build info with the version subcommand", func() {
+		session, err := gexec.Start(exec.Command(binPath, "version"), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say("ducatify dev"))
+		Expect(session.Out).To(gbytes.Say("commit: unknown"))
+		Expect(session.Out).To(gbytes.Say(`go: go\S+ \w+/\w+`))
+	})
+})
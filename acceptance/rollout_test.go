@@ -0,0 +1,95 @@
+package acceptance_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Generating a phased rollout", func() {
+	var rolloutDir string
+
+	rollout := func() {
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			"-rollout", rolloutDir,
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+	}
+
+	fileNames := func() []string {
+		files, err := ioutil.ReadDir(rolloutDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		names := []string{}
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		var err error
+		rolloutDir, err = ioutil.TempDir("", "ducatify-rollout")
+		Expect(err).NotTo(HaveOccurred())
+
+		rollout()
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(rolloutDir)
+	})
+
+	It("writes the stages in deploy order along with a plan", func() {
+		Expect(fileNames()).To(Equal([]string{
+			"01-ducati-db-and-connet.yml",
+			"02-cells-cell_z1.yml",
+			"03-cells-cell_z2.yml",
+			"04-cells-colocated_z3.yml",
+			"05-finish.yml",
+			"plan.txt",
+		}))
+
+		plan, err := ioutil.ReadFile(filepath.Join(rolloutDir, "plan.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plan)).To(ContainSubstring("1. 01-ducati-db-and-connet.yml"))
+		Expect(string(plan)).To(ContainSubstring("5. 05-finish.yml"))
+	})
+
+	It("replaces an earlier rollout in the same directory without leaving stale stages or backups", func() {
+		Expect(ioutil.WriteFile(filepath.Join(rolloutDir, "06-cells-cell_z4.yml"), []byte("stale: stage\n"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(rolloutDir, "notes.md"), []byte("keep me\n"), 0644)).To(Succeed())
+
+		rollout()
+
+		Expect(fileNames()).To(Equal([]string{
+			"01-ducati-db-and-connet.yml",
+			"02-cells-cell_z1.yml",
+			"03-cells-cell_z2.yml",
+			"04-cells-colocated_z3.yml",
+			"05-finish.yml",
+			"notes.md",
+			"plan.txt",
+		}))
+	})
+
+	It("finishes with the fully transformed manifest", func() {
+		_, expectedOutput := loadFixture("skeleton_transformed")
+
+		finalBytes, err := ioutil.ReadFile(filepath.Join(rolloutDir, "05-finish.yml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var finalOutput map[string]interface{}
+		Expect(candiedyaml.Unmarshal(finalBytes, &finalOutput)).To(Succeed())
+		Expect(withoutProvenance(finalOutput)).To(Equal(expectedOutput))
+	})
+})
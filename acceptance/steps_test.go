@@ -0,0 +1,57 @@
+package acceptance_test
+
+import (
+	"os/exec"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Selecting steps", func() {
+	run := func(args ...string) *gexec.Session {
+		args = append([]string{
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+		}, args...)
+		session, err := gexec.Start(exec.Command(binPath, args...), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("runs only the steps given to -only", func() {
+		session := run("-only", "ducati-props,connet-props")
+		Eventually(session).Should(gexec.Exit(0))
+
+		_, vanilla := loadFixture("skeleton_vanilla")
+		var manifest map[string]interface{}
+		Expect(candiedyaml.Unmarshal(session.Out.Contents(), &manifest)).To(Succeed())
+		Expect(manifest["jobs"]).To(Equal(vanilla["jobs"]))
+		Expect(manifest["releases"]).To(Equal(vanilla["releases"]))
+		Expect(manifest["properties"]).To(HaveKey("ducati"))
+		Expect(manifest["properties"]).To(HaveKey("connet"))
+	})
+
+	It("leaves out the steps given to -skip", func() {
+		session := run("-skip", "acceptance-job,acceptance-props")
+		Eventually(session).Should(gexec.Exit(0))
+
+		var manifest map[string]interface{}
+		Expect(candiedyaml.Unmarshal(session.Out.Contents(), &manifest)).To(Succeed())
+		Expect(elementNames(manifest["jobs"])).NotTo(ContainElement("ducati-acceptance"))
+		Expect(elementNames(manifest["jobs"])).To(ContainElement("ducati_db"))
+	})
+
+	It("fails when a selected step needs one that is left out", func() {
+		session := run("-skip", "releases")
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(string(session.Err.Contents())).To(ContainSubstring("step db-job needs step releases"))
+	})
+
+	It("fails for an unknown step", func() {
+		session := run("-only", "nope")
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(string(session.Err.Contents())).To(ContainSubstring(`unknown step "nope"`))
+	})
+})
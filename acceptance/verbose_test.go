@@ -0,0 +1,33 @@
+package acceptance_test
+
+import (
+	"os/exec"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Verbose output", func() {
+	It("traces the steps to stderr and leaves stdout to the manifest", func() {
+		cmd := exec.Command(binPath,
+			"-diego", "fixtures/skeleton_vanilla.yml",
+			"-cfCreds", "fixtures/cf_creds.yml",
+			"-v",
+		)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		_, expectedOutput := loadFixture("skeleton_transformed")
+		var manifest map[string]interface{}
+		Expect(candiedyaml.Unmarshal(session.Out.Contents(), &manifest)).To(Succeed())
+		Expect(withoutProvenance(manifest)).To(Equal(expectedOutput))
+
+		Expect(session.Err).To(gbytes.Say("releases: updating releases"))
+		Expect(session.Err).To(gbytes.Say(`releases: done, 1 changes`))
+		Expect(session.Err).To(gbytes.Say("acceptance-props: done"))
+	})
+})
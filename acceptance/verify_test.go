@@ -0,0 +1,55 @@
+package acceptance_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Verifying a manifest", func() {
+	verify := func(path string) *gexec.Session {
+		session, err := gexec.Start(exec.Command(binPath, "verify", path), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("passes a transformed manifest", func() {
+		session := verify("fixtures/skeleton_transformed.yml")
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`\[PASS\] release ducati`))
+		Expect(session.Out).To(gbytes.Say(`\[PASS\] cell_z1 has ducati`))
+		Expect(session.Out).To(gbytes.Say(`\[PASS\] ducati_db has postgres`))
+		Expect(session.Out).To(gbytes.Say(`\[PASS\] cc_bridge_z1 has connet and route_registrar`))
+		Expect(session.Out).To(gbytes.Say(`11 passed, 0 failed`))
+	})
+
+	It("fails on drift and lists what is wrong", func() {
+		transformedBytes, _ := loadFixture("skeleton_transformed")
+		drifted := strings.Replace(string(transformedBytes), "network_id: ducati-overlay", "network_id: some-network", 1)
+
+		manifestFile, err := ioutil.TempFile("", "ducatify-verify")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(manifestFile.Name())
+		_, err = manifestFile.WriteString(drifted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifestFile.Close()).To(Succeed())
+
+		session := verify(manifestFile.Name())
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Out).To(gbytes.Say(`\[FAIL\] nsync network_id: is "some-network", expected "ducati-overlay"`))
+		Expect(session.Out).To(gbytes.Say(`10 passed, 1 failed`))
+	})
+
+	It("fails a manifest that was never transformed", func() {
+		session := verify("fixtures/skeleton_vanilla.yml")
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Out).To(gbytes.Say(`\[FAIL\] release ducati: missing`))
+	})
+})
@@ -0,0 +1,108 @@
+package ducatify
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Backend is a container networking implementation that Transform adds to
+// a diego manifest. The Transformer's generic steps call into the backend
+// for everything that differs between implementations.
+type Backend interface {
+	// Defaults sets the garden and nsync settings the backend needs.
+	Defaults(t *Transformer)
+
+	// Releases returns the releases to add to the manifest.
+	Releases(t *Transformer) []*Release
+
+	// AddJobs adds any jobs the backend runs besides the cell templates.
+	AddJobs(t *Transformer, manifest *Manifest) error
+
+	// ModifyCCBridge changes the cc_bridge jobs, if the backend needs to.
+	ModifyCCBridge(t *Transformer, manifest *Manifest, systemDomain string) error
+
+	// CellTemplates returns the templates to add to the named cell job.
+	CellTemplates(t *Transformer, job string) []*Template
+
+	// PropertyTrees returns the global property trees the backend sets.
+	PropertyTrees(t *Transformer) []PropertyTree
+
+	// AcceptanceErrand returns the backend's acceptance errand job and the
+	// property tree that receives the acceptance job config, or a nil job
+	// if the backend has no errand.
+	AcceptanceErrand(t *Transformer) (job *Job, propertyTree string)
+
+	// Verify checks that a manifest has the backend's jobs, templates and
+	// properties besides those on the cells, see Transformer.Verify.
+	Verify(t *Transformer, manifest *Manifest) ([]VerifyResult, error)
+
+	// SetupStage names and describes the first stage of a phased rollout,
+	// which adds everything but the cell changes.
+	SetupStage() (name, description string)
+}
+
+// PropertyTree is a top-level manifest property that a backend sets. Its
+// step is named after it with a "-props" suffix.
+type PropertyTree struct {
+	Name  string
+	Value map[interface{}]interface{}
+}
+
+var (
+	backendsLock sync.RWMutex
+	backends     = map[string]Backend{
+		"ducati":  ducatiBackend{},
+		"flannel": flannelBackend{},
+	}
+)
+
+// RegisterBackend makes a backend available to UseBackend, replacing any
+// existing backend with the same name.
+func RegisterBackend(name string, backend Backend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+	backends[name] = backend
+}
+
+// BackendNames returns the names of all registered backends in sorted order.
+func BackendNames() []string {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+
+	names := []string{}
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupBackend returns the backend registered under name.
+func LookupBackend(name string) (Backend, bool) {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+
+	backend, ok := backends[name]
+	return backend, ok
+}
+
+// UseBackend switches the Transformer to the named backend and applies the
+// backend's garden and nsync defaults.
+func (t *Transformer) UseBackend(name string) error {
+	backend, ok := LookupBackend(name)
+	if !ok {
+		return fmt.Errorf("unknown backend %q, expected one of %v", name, BackendNames())
+	}
+	t.Backend = name
+	backend.Defaults(t)
+	return nil
+}
+
+func (t *Transformer) backend() (Backend, error) {
+	backend, ok := LookupBackend(t.Backend)
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q, expected one of %v", t.Backend, BackendNames())
+	}
+	return backend, nil
+}
@@ -0,0 +1,162 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backends", func() {
+	var (
+		manifest            map[interface{}]interface{}
+		acceptanceJobConfig map[interface{}]interface{}
+		transformer         *ducatify.Transformer
+	)
+
+	jobNamed := func(name string) map[interface{}]interface{} {
+		for _, job := range manifest["jobs"].([]interface{}) {
+			if job.(map[interface{}]interface{})["name"] == name {
+				return job.(map[interface{}]interface{})
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		acceptanceJobConfig = map[interface{}]interface{}{"api": "api.some.system.domain"}
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cc_bridge_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z1", "templates": []interface{}{}},
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	It("ships the ducati and flannel backends, using ducati by default", func() {
+		Expect(ducatify.BackendNames()).To(ContainElement("ducati"))
+		Expect(ducatify.BackendNames()).To(ContainElement("flannel"))
+		Expect(transformer.Backend).To(Equal("ducati"))
+	})
+
+	It("keeps New's defaults when switching to the ducati backend", func() {
+		Expect(transformer.UseBackend("ducati")).To(Succeed())
+		Expect(transformer).To(Equal(ducatify.New()))
+	})
+
+	It("returns an error for an unknown backend", func() {
+		Expect(transformer.UseBackend("nope")).To(MatchError(ContainSubstring(`unknown backend "nope"`)))
+
+		transformer.Backend = "nope"
+		_, err := transformer.Transform(manifest, acceptanceJobConfig, "some.system.domain")
+		Expect(err).To(MatchError(ContainSubstring(`unknown backend "nope"`)))
+	})
+
+	Context("with the flannel backend", func() {
+		BeforeEach(func() {
+			Expect(transformer.UseBackend("flannel")).To(Succeed())
+			var err error
+			manifest, err = transformer.Transform(manifest, acceptanceJobConfig, "some.system.domain")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("adds the flannel release and cell template", func() {
+			Expect(manifest["releases"]).To(Equal([]interface{}{
+				map[interface{}]interface{}{"name": "flannel", "version": "latest"},
+			}))
+			Expect(jobNamed("cell_z1")["templates"]).To(Equal([]interface{}{
+				map[interface{}]interface{}{"name": "flannel", "release": "flannel"},
+			}))
+		})
+
+		It("adds no database, connet or acceptance jobs", func() {
+			Expect(manifest["jobs"]).To(HaveLen(3))
+			Expect(jobNamed("cc_bridge_z1")["templates"]).To(BeEmpty())
+		})
+
+		It("points garden and nsync at flannel", func() {
+			props := manifest["properties"].(map[interface{}]interface{})
+			garden := props["garden"].(map[interface{}]interface{})
+			Expect(garden["network_plugin"]).To(Equal("/var/vcap/packages/flannel-cni/bin/guardian-cni-adapter"))
+			Expect(garden["shared_mounts"]).To(ConsistOf("/var/vcap/data/flannel/container-netns"))
+
+			nsync := props["diego"].(map[interface{}]interface{})["nsync"].(map[interface{}]interface{})
+			Expect(nsync["network_id"]).To(Equal("flannel-overlay"))
+		})
+
+		It("sets the flannel properties from the overlay settings", func() {
+			props := manifest["properties"].(map[interface{}]interface{})
+			Expect(props).NotTo(HaveKey("ducati"))
+			Expect(props).NotTo(HaveKey("acceptance-with-cf"))
+			Expect(props["flannel"]).To(Equal(map[interface{}]interface{}{
+				"network":    "10.255.0.0/16",
+				"subnet_len": 24,
+				"backend":    map[interface{}]interface{}{"type": "vxlan"},
+				"etcd": map[interface{}]interface{}{
+					"endpoints": []interface{}{"http://etcd.service.cf.internal:4001"},
+				},
+			}))
+		})
+	})
+
+	It("uses registered backends", func() {
+		ducatify.RegisterBackend("some-backend", &fakeBackend{})
+		Expect(transformer.UseBackend("some-backend")).To(Succeed())
+		Expect(transformer.NsyncNetworkID).To(Equal("some-network"))
+
+		var err error
+		manifest, err = transformer.Transform(manifest, acceptanceJobConfig, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(jobNamed("cell_z1")["templates"]).To(Equal([]interface{}{
+			map[interface{}]interface{}{"name": "some-template", "release": "some-release"},
+		}))
+	})
+})
+
+type fakeBackend struct{}
+
+func (*fakeBackend) Defaults(t *ducatify.Transformer) {
+	t.NsyncNetworkID = "some-network"
+}
+
+func (*fakeBackend) Releases(t *ducatify.Transformer) []*ducatify.Release {
+	return nil
+}
+
+func (*fakeBackend) AddJobs(t *ducatify.Transformer, manifest *ducatify.Manifest) error {
+	return nil
+}
+
+func (*fakeBackend) ModifyCCBridge(t *ducatify.Transformer, manifest *ducatify.Manifest, systemDomain string) error {
+	return nil
+}
+
+func (*fakeBackend) CellTemplates(t *ducatify.Transformer, job string) []*ducatify.Template {
+	return []*ducatify.Template{{Name: "some-template", Release: "some-release"}}
+}
+
+func (*fakeBackend) PropertyTrees(t *ducatify.Transformer) []ducatify.PropertyTree {
+	return nil
+}
+
+func (*fakeBackend) AcceptanceErrand(t *ducatify.Transformer) (*ducatify.Job, string) {
+	return nil, ""
+}
+
+func (*fakeBackend) Verify(t *ducatify.Transformer, manifest *ducatify.Manifest) ([]ducatify.VerifyResult, error) {
+	return nil, nil
+}
+
+func (*fakeBackend) SetupStage() (string, string) {
+	return "setup", "set up"
+}
@@ -0,0 +1,167 @@
+package ducatify
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// CellFilter narrows down the cell jobs that ducati is enabled on. Every
+// entry is a path.Match pattern. A cell must match at least one pattern of
+// each non-empty include list and none of the exclude patterns.
+type CellFilter struct {
+	IncludeJobs  []string `yaml:"include_jobs"`
+	ExcludeJobs  []string `yaml:"exclude_jobs"`
+	IncludeZones []string `yaml:"include_zones"`
+	ExcludeZones []string `yaml:"exclude_zones"`
+	IncludeAZs   []string `yaml:"include_azs"`
+	ExcludeAZs   []string `yaml:"exclude_azs"`
+}
+
+// CellAssignment records whether ducati is enabled on a cell job, and why
+// not if it isn't.
+type CellAssignment struct {
+	Job    string
+	Zone   string
+	AZs    []string
+	Ducati bool
+	Reason string
+}
+
+// CellAssignments lists every cell job in the manifest along with whether
+// the Transformer's CellFilter enables ducati on it.
+func (t *Transformer) CellAssignments(manifest map[interface{}]interface{}) ([]CellAssignment, error) {
+	m, err := DecodeManifest(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("decoding manifest: %s", err)
+	}
+	_, assignments, err := t.cellJobs(m)
+	return assignments, err
+}
+
+// ducatiCells returns the cell jobs that ducati is enabled on, and whether
+// that leaves out any cells.
+func (t *Transformer) ducatiCells(manifest *Manifest) (cells []*Job, partial bool, err error) {
+	jobs, assignments, err := t.cellJobs(manifest)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for i, job := range jobs {
+		if assignments[i].Ducati {
+			cells = append(cells, job)
+		} else {
+			partial = true
+		}
+	}
+	return cells, partial, nil
+}
+
+func (t *Transformer) cellJobs(manifest *Manifest) (jobs []*Job, assignments []CellAssignment, err error) {
+	for _, job := range manifest.Jobs {
+		if !isCellJob(job.Name) {
+			continue
+		}
+		assignment, err := t.Cells.assign(manifest, job)
+		if err != nil {
+			return nil, nil, err
+		}
+		jobs = append(jobs, job)
+		assignments = append(assignments, assignment)
+	}
+	return jobs, assignments, nil
+}
+
+func isCellJob(name string) bool {
+	return strings.HasPrefix(name, "cell_z") || strings.HasPrefix(name, "colocated_z")
+}
+
+func (f CellFilter) assign(manifest *Manifest, job *Job) (CellAssignment, error) {
+	assignment := CellAssignment{Job: job.Name, AZs: append([]string{}, job.AZs...)}
+
+	zone, err := jobZone(manifest, job)
+	if err != nil {
+		return assignment, err
+	}
+	assignment.Zone = zone
+
+	checks := []struct {
+		label            string
+		values           []string
+		include, exclude []string
+	}{
+		{"job", []string{assignment.Job}, f.IncludeJobs, f.ExcludeJobs},
+		{"zone", nonEmpty(assignment.Zone), f.IncludeZones, f.ExcludeZones},
+		{"az", assignment.AZs, f.IncludeAZs, f.ExcludeAZs},
+	}
+	for _, check := range checks {
+		for _, pattern := range check.exclude {
+			matched, err := matchAny(pattern, check.values)
+			if err != nil {
+				return assignment, err
+			}
+			if matched {
+				assignment.Reason = fmt.Sprintf("%s excluded by %q", check.label, pattern)
+				return assignment, nil
+			}
+		}
+
+		if len(check.include) == 0 {
+			continue
+		}
+		included := false
+		for _, pattern := range check.include {
+			matched, err := matchAny(pattern, check.values)
+			if err != nil {
+				return assignment, err
+			}
+			included = included || matched
+		}
+		if !included {
+			assignment.Reason = fmt.Sprintf("%s not included by %v", check.label, check.include)
+			return assignment, nil
+		}
+	}
+
+	assignment.Ducati = true
+	return assignment, nil
+}
+
+// jobZone returns the diego zone configured on a job, if any. Like BOSH,
+// it falls back to the global properties when the job sets no zone.
+func jobZone(manifest *Manifest, job *Job) (string, error) {
+	zone, err := lookupProperty(job.Properties, "diego.rep.zone")
+	if err != nil {
+		return "", fmt.Errorf("job %s: %s", job.Name, err)
+	}
+	if zone == nil {
+		zone, err = lookupProperty(manifest.Properties, "diego.rep.zone")
+		if err != nil {
+			return "", err
+		}
+	}
+	if zone == nil {
+		return "", nil
+	}
+	return fmt.Sprintf("%v", zone), nil
+}
+
+func matchAny(pattern string, values []string) (bool, error) {
+	for _, value := range values {
+		matched, err := path.Match(pattern, value)
+		if err != nil {
+			return false, fmt.Errorf("bad cell filter pattern %q: %s", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
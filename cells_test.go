@@ -0,0 +1,155 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Selecting cells", func() {
+	var (
+		manifest    map[interface{}]interface{}
+		transformer *ducatify.Transformer
+		warnings    []string
+	)
+
+	jobNamed := func(name string) map[interface{}]interface{} {
+		for _, job := range manifest["jobs"].([]interface{}) {
+			if job.(map[interface{}]interface{})["name"] == name {
+				return job.(map[interface{}]interface{})
+			}
+		}
+		Fail("missing job " + name)
+		return nil
+	}
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		recordWarnings(transformer, &warnings)
+
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "templates": []interface{}{}},
+				inAZs(cellJob("cell_z1", "z1"), "us-east-1a"),
+				inAZs(cellJob("cell_z2", "z2"), "us-east-1b"),
+				cellJob("colocated_z3", "z3"),
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{"a_thing": "a_value"},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	transform := func() error {
+		transformed, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		if err == nil {
+			manifest = transformed
+		}
+		return err
+	}
+
+	It("enables ducati on every cell by default", func() {
+		assignments, err := transformer.CellAssignments(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignments).To(HaveLen(3))
+		for _, assignment := range assignments {
+			Expect(assignment.Ducati).To(BeTrue())
+		}
+	})
+
+	It("filters by job name", func() {
+		transformer.Cells.ExcludeJobs = []string{"colocated_*"}
+
+		assignments, err := transformer.CellAssignments(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignments[2]).To(Equal(ducatify.CellAssignment{
+			Job:    "colocated_z3",
+			Zone:   "z3",
+			AZs:    []string{},
+			Ducati: false,
+			Reason: `job excluded by "colocated_*"`,
+		}))
+	})
+
+	It("filters by zone", func() {
+		transformer.Cells.IncludeZones = []string{"z2"}
+
+		assignments, err := transformer.CellAssignments(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignments[0].Ducati).To(BeFalse())
+		Expect(assignments[0].Reason).To(Equal("zone not included by [z2]"))
+		Expect(assignments[1].Ducati).To(BeTrue())
+		Expect(assignments[2].Ducati).To(BeFalse())
+	})
+
+	It("takes the zone from the global properties when a job sets none", func() {
+		jobs := manifest["jobs"].([]interface{})
+		jobs[3] = cellJob("colocated_z3", "")
+		diego := manifest["properties"].(map[interface{}]interface{})["diego"].(map[interface{}]interface{})
+		diego["rep"] = map[interface{}]interface{}{"zone": "z2"}
+		transformer.Cells.IncludeZones = []string{"z2"}
+
+		assignments, err := transformer.CellAssignments(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignments[0].Zone).To(Equal("z1"))
+		Expect(assignments[0].Ducati).To(BeFalse())
+		Expect(assignments[2].Zone).To(Equal("z2"))
+		Expect(assignments[2].Ducati).To(BeTrue())
+	})
+
+	It("filters by AZ", func() {
+		transformer.Cells.IncludeAZs = []string{"us-east-1a"}
+
+		assignments, err := transformer.CellAssignments(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignments[0].Ducati).To(BeTrue())
+		Expect(assignments[1].Ducati).To(BeFalse())
+		Expect(assignments[2].Ducati).To(BeFalse())
+	})
+
+	It("rejects bad patterns", func() {
+		transformer.Cells.IncludeJobs = []string{"cell_["}
+
+		_, err := transformer.CellAssignments(manifest)
+		Expect(err).To(MatchError(ContainSubstring(`bad cell filter pattern "cell_["`)))
+	})
+
+	Context("when only some cells are selected", func() {
+		BeforeEach(func() {
+			transformer.Cells.IncludeJobs = []string{"cell_z1"}
+		})
+
+		It("adds the ducati template to the selected cells only and reports the rest", func() {
+			Expect(transform()).To(Succeed())
+
+			Expect(jobNamed("cell_z1")["templates"]).To(ContainElement(
+				map[interface{}]interface{}{"name": "ducati", "release": "ducati"}))
+			Expect(jobNamed("cell_z2")["templates"]).To(BeEmpty())
+			Expect(jobNamed("colocated_z3")["templates"]).To(BeEmpty())
+
+			Expect(warnings).To(ConsistOf(
+				"ducati not enabled on cell_z2: job not included by [cell_z1]",
+				"ducati not enabled on colocated_z3: job not included by [cell_z1]",
+				"nsync network_id applies to every app, including apps placed on cells without ducati",
+			))
+		})
+
+		It("scopes the garden properties to the selected cells", func() {
+			Expect(transform()).To(Succeed())
+
+			Expect(manifest["properties"]).To(HaveKeyWithValue("garden",
+				map[interface{}]interface{}{"a_thing": "a_value"}))
+
+			jobProps := jobNamed("cell_z1")["properties"].(map[interface{}]interface{})
+			Expect(jobProps["garden"]).To(HaveKeyWithValue(
+				"network_plugin", "/var/vcap/packages/ducati/bin/guardian-cni-adapter"))
+			Expect(jobNamed("cell_z2")["properties"]).NotTo(HaveKey("garden"))
+		})
+	})
+})
@@ -16,8 +16,11 @@ export PATH=$PATH:$GOPATH/bin
 
 cd src/github.com/cloudfoundry-incubator/ducatify/cmd/ducatify
 
+commit=$(git rev-parse HEAD)
+ldflags="-X github.com/cloudfoundry-incubator/ducatify.Version=${version} -X github.com/cloudfoundry-incubator/ducatify.Commit=${commit}"
+
 for os in linux darwin windows; do
-  GOOS=$os go build -o $OUT_BINARIES/ducatify-$os &
+  GOOS=$os go build -ldflags "$ldflags" -o $OUT_BINARIES/ducatify-$os &
 done
 
 wait
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify"
+)
+
+const (
+	batchDiegoFile     = "diego.yml"
+	batchCFCredsFile   = "cf_creds.yml"
+	batchOverridesFile = "ducatify.yml"
+)
+
+type batchEnvironment struct {
+	Name      string                      `yaml:"name"`
+	Diego     string                      `yaml:"diego"`
+	CFCreds   string                      `yaml:"cfCreds"`
+	Output    string                      `yaml:"output"`
+	Profile   string                      `yaml:"profile"`
+	Overrides map[interface{}]interface{} `yaml:"overrides"`
+}
+
+type batchList struct {
+	Environments []batchEnvironment `yaml:"environments"`
+}
+
+type batchResult struct {
+	env batchEnvironment
+	err error
+}
+
+func runBatch(args []string) int {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	outDir := flags.String("out", "", "directory for transformed manifests of environments that don't name an output")
+	workers := flags.Int("workers", 4, "number of environments to transform concurrently")
+	profile := flags.String("profile", "bosh-lite", "profile for environments that don't name one")
+	profileDir := flags.String("profileDir", defaultProfileDir(), "directory of additional <name>.yml profiles")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s batch [flags] <environments-dir | environments-list.yml>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *workers < 1 {
+		log.Printf("workers must be at least 1")
+		return 2
+	}
+
+	err := loadProfiles(*profileDir)
+	if err != nil {
+		log.Printf("loading profiles: %s", err)
+		return 1
+	}
+
+	envs, err := loadBatchEnvironments(flags.Arg(0), *outDir, *profile)
+	if err != nil {
+		log.Printf("loading environments: %s", err)
+		return 1
+	}
+
+	results := transformEnvironments(envs, *workers)
+
+	failed := printBatchSummary(os.Stdout, results)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func loadBatchEnvironments(source, outDir, defaultProfile string) ([]batchEnvironment, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var envs []batchEnvironment
+	if info.IsDir() {
+		envs, err = loadBatchDirectory(source)
+	} else {
+		envs, err = loadBatchList(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for i := range envs {
+		env := &envs[i]
+		if env.Name == "" {
+			return nil, fmt.Errorf("environment %d has no name", i+1)
+		}
+		if seen[env.Name] {
+			return nil, fmt.Errorf("duplicate environment name %q", env.Name)
+		}
+		seen[env.Name] = true
+
+		if env.Profile == "" {
+			env.Profile = defaultProfile
+		}
+
+		if env.Output == "" {
+			if outDir == "" {
+				return nil, fmt.Errorf("environment %q has no output and no -out directory was given", env.Name)
+			}
+			env.Output = filepath.Join(outDir, env.Name+".yml")
+		}
+	}
+	return envs, nil
+}
+
+// loadBatchDirectory treats every subdirectory of dir as an environment
+// holding diego.yml, cf_creds.yml and an optional ducatify.yml of
+// Transformer overrides.
+func loadBatchDirectory(dir string) ([]batchEnvironment, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	envs := []batchEnvironment{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		envDir := filepath.Join(dir, entry.Name())
+		env := batchEnvironment{
+			Name:    entry.Name(),
+			Diego:   filepath.Join(envDir, batchDiegoFile),
+			CFCreds: filepath.Join(envDir, batchCFCredsFile),
+		}
+
+		overrideBytes, err := ioutil.ReadFile(filepath.Join(envDir, batchOverridesFile))
+		if err == nil {
+			err = candiedyaml.Unmarshal(overrideBytes, &env.Overrides)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s overrides: %s", env.Name, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		envs = append(envs, env)
+	}
+	return envs, nil
+}
+
+// loadBatchList reads a yaml file listing environments. Relative paths in
+// it are resolved against the directory holding the list.
+func loadBatchList(path string) ([]batchEnvironment, error) {
+	listBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list batchList
+	err = candiedyaml.Unmarshal(listBytes, &list)
+	if err != nil {
+		return nil, fmt.Errorf("parsing environment list: %s", err)
+	}
+
+	base := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(base, p)
+	}
+	for i := range list.Environments {
+		env := &list.Environments[i]
+		env.Diego = resolve(env.Diego)
+		env.CFCreds = resolve(env.CFCreds)
+		env.Output = resolve(env.Output)
+	}
+	return list.Environments, nil
+}
+
+func transformEnvironments(envs []batchEnvironment, workers int) []batchResult {
+	results := make([]batchResult, len(envs))
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i] = batchResult{env: envs[i], err: transformEnvironment(envs[i])}
+			}
+		}()
+	}
+
+	for i := range envs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return results
+}
+
+func transformEnvironment(env batchEnvironment) error {
+	transformer := ducatify.New()
+	err := transformer.ApplyProfile(env.Profile)
+	if err != nil {
+		return err
+	}
+	err = applyOverrides(transformer, env.Overrides)
+	if err != nil {
+		return err
+	}
+	transformer.Warn = func(msg string) {
+		log.Printf("%s: warning: %s", env.Name, msg)
+	}
+
+	if env.Diego == "" {
+		return fmt.Errorf("missing diego manifest path")
+	}
+	if env.CFCreds == "" {
+		return fmt.Errorf("missing cf creds path")
+	}
+
+	vanillaBytes, err := ioutil.ReadFile(env.Diego)
+	if err != nil {
+		return fmt.Errorf("reading diego manifest: %s", err)
+	}
+
+	cfCredBytes, err := ioutil.ReadFile(env.CFCreds)
+	if err != nil {
+		return fmt.Errorf("reading cf creds config: %s", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(env.Output), 0755)
+	if err != nil {
+		return fmt.Errorf("creating output directory: %s", err)
+	}
+
+	output, err := openOutputFile(env.Output)
+	if err != nil {
+		return fmt.Errorf("reading output file: %s", err)
+	}
+
+	transformedBytes, err := transformBytes(transformer, vanillaBytes, cfCredBytes)
+	if err != nil {
+		return err
+	}
+
+	err = output.Write(transformedBytes)
+	if err != nil {
+		return fmt.Errorf("writing output: %s", err)
+	}
+	return nil
+}
+
+func printBatchSummary(out *os.File, results []batchResult) int {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ENVIRONMENT\tSTATUS\tDETAILS")
+
+	failed := 0
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\tfailed\t%s\n", result.env.Name, result.err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\tok\t%s\n", result.env.Name, result.env.Output)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\n%d succeeded, %d failed\n", len(results)-failed, failed)
+	return failed
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify"
+)
+
+// applyOverrides sets every Transformer field named in overrides, using the
+// field's yaml name, and leaves the rest untouched. Keys that name no field
+// are rejected. A backend is switched to first, so that its defaults don't
+// replace the other overrides.
+func applyOverrides(transformer *ducatify.Transformer, overrides map[interface{}]interface{}) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	err := checkSettingNames("", overrides, reflect.TypeOf(ducatify.Transformer{}))
+	if err != nil {
+		return err
+	}
+
+	if backendVal, ok := overrides["backend"]; ok {
+		backend, ok := backendVal.(string)
+		if !ok {
+			return fmt.Errorf("backend must be a backend name")
+		}
+		err = transformer.UseBackend(backend)
+		if err != nil {
+			return err
+		}
+	}
+
+	overrideBytes, err := candiedyaml.Marshal(overrides)
+	if err != nil {
+		return fmt.Errorf("marshalling overrides: %s", err)
+	}
+
+	err = candiedyaml.Unmarshal(overrideBytes, transformer)
+	if err != nil {
+		return fmt.Errorf("unmarshalling overrides: %s", err)
+	}
+	return nil
+}
+
+// checkSettingNames returns an error naming the first key in val that is
+// not the yaml name of a field of typ, looking into nested settings too.
+// Unmarshalling would silently drop such a key, leaving the default in
+// place of a misspelled setting. Values of the wrong type are left for
+// unmarshalling to report.
+func checkSettingNames(path string, val interface{}, typ reflect.Type) error {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		settings, ok := val.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		fields := yamlFields(typ)
+		for _, key := range sortedKeys(settings) {
+			field, ok := fields[key]
+			if !ok {
+				return fmt.Errorf("unknown setting %q", path+key)
+			}
+			err := checkSettingNames(path+key+".", settings[key], field)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		entries, ok := val.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(entries) {
+			err := checkSettingNames(path+key+".", entries[key], typ.Elem())
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		items, ok := val.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range items {
+			err := checkSettingNames(fmt.Sprintf("%s[%d].", strings.TrimSuffix(path, "."), i), item, typ.Elem())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// yamlFields maps the yaml names of the fields of a struct type to their
+// types.
+func yamlFields(typ reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func sortedKeys(m map[interface{}]interface{}) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, fmt.Sprintf("%v", key))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loadConfig applies the transformer settings in the yaml file at path.
+func loadConfig(transformer *ducatify.Transformer, path string) error {
+	configBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var overrides map[interface{}]interface{}
+	err = candiedyaml.Unmarshal(configBytes, &overrides)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %s", path, err)
+	}
+	return applyOverrides(transformer, overrides)
+}
+
+func defaultProfileDir() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ducatify", "profiles")
+}
+
+// loadProfiles registers a profile for every <name>.yml file in dir. Each
+// file holds transformer overrides, optionally on top of the built-in
+// profile named by its "base" key. Any profile that does not parse, or that
+// names an unknown setting, fails the whole load, even when it is not the
+// profile selected.
+func loadProfiles(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		profileBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var overrides map[interface{}]interface{}
+		err = candiedyaml.Unmarshal(profileBytes, &overrides)
+		if err != nil {
+			return fmt.Errorf("parsing profile %s: %s", path, err)
+		}
+
+		var baseProfile ducatify.Profile
+		if baseVal, ok := overrides["base"]; ok {
+			base, ok := baseVal.(string)
+			if !ok {
+				return fmt.Errorf("parsing profile %s: base must be a profile name", path)
+			}
+			baseProfile, ok = ducatify.LookupProfile(base)
+			if !ok {
+				return fmt.Errorf("parsing profile %s: unknown base profile %q", path, base)
+			}
+			delete(overrides, "base")
+		}
+
+		// check the overrides now, since a Profile cannot report errors
+		err = applyOverrides(ducatify.New(), overrides)
+		if err != nil {
+			return fmt.Errorf("parsing profile %s: %s", path, err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), ".yml")
+		ducatify.RegisterProfile(name, customProfile(baseProfile, overrides))
+	}
+	return nil
+}
+
+func customProfile(base ducatify.Profile, overrides map[interface{}]interface{}) ducatify.Profile {
+	return func(t *ducatify.Transformer) {
+		if base != nil {
+			base(t)
+		}
+		applyOverrides(t, overrides)
+	}
+}
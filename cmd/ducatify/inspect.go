@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify"
+)
+
+func runInspect(args []string) int {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the summary as JSON instead of tables")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s inspect [flags] <manifest.yml>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	manifestBytes, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		log.Printf("reading manifest: %s", err)
+		return 1
+	}
+	var manifest map[interface{}]interface{}
+	err = candiedyaml.Unmarshal(manifestBytes, &manifest)
+	if err != nil {
+		log.Printf("unmarshalling yaml: %s", err)
+		return 1
+	}
+
+	inspection, err := ducatify.New().Inspect(manifest)
+	if err != nil {
+		log.Printf("inspecting: %s", err)
+		return 1
+	}
+
+	if *asJSON {
+		inspectionBytes, err := json.MarshalIndent(inspection, "", "  ")
+		if err != nil {
+			log.Printf("marshalling json: %s", err)
+			return 1
+		}
+		os.Stdout.Write(append(inspectionBytes, '\n'))
+		return 0
+	}
+
+	printInspection(os.Stdout, inspection)
+	return 0
+}
+
+func printInspection(out io.Writer, inspection *ducatify.Inspection) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	section := func(title string) {
+		fmt.Fprintf(w, "\n%s\n", title)
+	}
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return "-"
+		}
+		return strings.Join(items, ", ")
+	}
+	value := func(val string) string {
+		if val == "" {
+			return "-"
+		}
+		return val
+	}
+
+	switch {
+	case inspection.SchemaVersionProblem == "":
+		fmt.Fprintf(w, "SCHEMA VERSION\t%d\n", inspection.SchemaVersion)
+	case inspection.SchemaVersion == 0:
+		fmt.Fprintf(w, "SCHEMA VERSION\tunknown (%s)\n", inspection.SchemaVersionProblem)
+	default:
+		fmt.Fprintf(w, "SCHEMA VERSION\t%d (%s)\n", inspection.SchemaVersion, inspection.SchemaVersionProblem)
+	}
+
+	section("RELEASES")
+	fmt.Fprintln(w, "NAME\tVERSION")
+	for _, release := range inspection.Releases {
+		fmt.Fprintf(w, "%s\t%s\n", release.Name, release.Version)
+	}
+
+	section("JOBS")
+	fmt.Fprintln(w, "JOB\tINSTANCES\tTEMPLATES")
+	for _, job := range inspection.Jobs {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", job.Name, job.Instances, list(job.Templates))
+	}
+
+	section("DATABASE")
+	if db := inspection.Database; db == nil {
+		fmt.Fprintln(w, "none")
+	} else {
+		fmt.Fprintf(w, "location\t%s\n", db.Location)
+		fmt.Fprintf(w, "address\t%s:%s\n", value(db.Host), value(db.Port))
+		fmt.Fprintf(w, "database\t%s\n", value(db.Name))
+		fmt.Fprintf(w, "username\t%s\n", value(db.Username))
+		fmt.Fprintf(w, "password set\t%t\n", db.PasswordSet)
+		fmt.Fprintf(w, "ssl_mode\t%s\n", value(db.SSLMode))
+		fmt.Fprintf(w, "credentials\t%s\n", db.CredentialsSource)
+	}
+
+	section("GARDEN")
+	fmt.Fprintln(w, "SCOPE\tNETWORK PLUGIN\tEXTRA ARGS\tSHARED MOUNTS\tDNS SERVERS")
+	for _, garden := range inspection.Garden {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", garden.Scope, value(garden.NetworkPlugin),
+			list(garden.NetworkPluginExtraArgs), list(garden.SharedMounts), list(garden.DNSServers))
+	}
+
+	section("NSYNC")
+	fmt.Fprintf(w, "network_id\t%s\n", value(inspection.NsyncNetworkID))
+
+	section("ROUTES")
+	fmt.Fprintln(w, "SCOPE\tNAME\tPORT\tURIS")
+	for _, route := range inspection.Routes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", route.Scope, route.Name, value(route.Port), list(route.URIs))
+	}
+	w.Flush()
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"runtime"
 	"strings"
 
 	"github.com/cloudfoundry-incubator/candiedyaml"
@@ -13,37 +16,245 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "batch" {
+		os.Exit(runBatch(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "inspect" {
+		os.Exit(runInspect(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		printVersion()
+		return
+	}
+
 	var diegoManifestPath string
 	var cfCredsPath string
+	var gardenPluginConflict string
+	var propertyConflicts string
+	var outputPath string
+	var inPlace bool
+	var verbose bool
+	var migrate bool
+	var profile string
+	var profileDir string
+	var rolloutDir string
+	var configPath string
+	var backend string
+	var onlySteps, skipSteps string
+	var includeJobs, excludeJobs, includeZones, excludeZones, includeAZs, excludeAZs string
+
+	transformer := ducatify.New()
 
 	flag.StringVar(&diegoManifestPath, "diego", "", "path to vanilla diego manifest")
 	flag.StringVar(&cfCredsPath, "cfCreds", "", "path to cf creds config")
+	flag.StringVar(&gardenPluginConflict, "gardenPluginConflict", "",
+		"what to do when the manifest already sets a different garden network_plugin: warn (the default) or fail")
+	flag.StringVar(&propertyConflicts, "propertyConflicts", "",
+		"how to handle existing ducati, connet, acceptance-with-cf and flannel properties: overwrite, merge or fail, "+
+			"either for all trees or per tree, e.g. ducati=merge,connet=fail")
+	flag.IntVar(&transformer.ConnetPort, "connetPort", transformer.ConnetPort, "port registered for the connet route")
+	flag.StringVar(&transformer.ConnetRegistrationInterval, "connetRegistrationInterval", transformer.ConnetRegistrationInterval,
+		"registration interval for the connet route")
+	flag.StringVar(&transformer.ConnetHostnamePrefix, "connetHostnamePrefix", transformer.ConnetHostnamePrefix,
+		"hostname prefix for the connet route, prepended to the system domain")
+	flag.StringVar((*string)(&transformer.ServiceDiscovery), "serviceDiscovery", string(transformer.ServiceDiscovery),
+		"how the ducati daemons find the database: consul, or bosh-dns to use a BOSH DNS alias instead of a consul_agent on ducati_db")
+	flag.BoolVar(&transformer.Strict, "strict", false,
+		"fail instead of creating property blocks such as properties.garden that the manifest leaves to job defaults")
+	flag.BoolVar(&transformer.DeriveGardenDNSServers, "deriveGardenDNS", true,
+		"take the containers' DNS servers from the cells' subnets and consul recursors, falling back to the profile's servers")
+	flag.StringVar(&transformer.OverlayNetwork, "overlayNetwork", transformer.OverlayNetwork,
+		"CIDR from which the backend allocates container subnets")
+	flag.IntVar(&transformer.OverlaySubnetPrefixLength, "overlaySubnetPrefixLength", transformer.OverlaySubnetPrefixLength,
+		"prefix length of the container subnet given to each cell")
+	flag.StringVar(&includeJobs, "includeJobs", "", "comma-separated patterns of cell job names to enable ducati on")
+	flag.StringVar(&excludeJobs, "excludeJobs", "", "comma-separated patterns of cell job names to leave without ducati")
+	flag.StringVar(&includeZones, "includeZones", "", "comma-separated patterns of diego zones to enable ducati in")
+	flag.StringVar(&excludeZones, "excludeZones", "", "comma-separated patterns of diego zones to leave without ducati")
+	flag.StringVar(&includeAZs, "includeAZs", "", "comma-separated patterns of AZs to enable ducati in")
+	flag.StringVar(&excludeAZs, "excludeAZs", "", "comma-separated patterns of AZs to leave without ducati")
+	flag.StringVar(&onlySteps, "only", "", "comma-separated steps to run, leaving out all others, e.g. ducati-props,connet-props")
+	flag.StringVar(&skipSteps, "skip", "", "comma-separated steps to leave out, e.g. db-job,acceptance-job")
+	flag.StringVar(&outputPath, "o", "", "write the transformed manifest to this path instead of stdout")
+	flag.BoolVar(&inPlace, "in-place", false, "replace the diego manifest with the transformed manifest")
+	flag.BoolVar(&verbose, "v", false, "trace the transformation steps to stderr")
+	flag.BoolVar(&migrate, "migrate", false,
+		"update a manifest transformed by an earlier ducatify to the current layout instead of transforming it")
+	flag.StringVar(&rolloutDir, "rollout", "", "write a series of staged manifests and a plan to this directory instead of one manifest")
+	flag.StringVar(&configPath, "config", "", "yaml file of transformer settings, applied on top of the profile")
+	flag.StringVar(&backend, "backend", "", fmt.Sprintf("container networking backend to add, one of %v (default ducati)", ducatify.BackendNames()))
+	flag.StringVar(&profile, "profile", "bosh-lite", "environment profile providing defaults, e.g. bosh-lite, aws or vsphere")
+	flag.StringVar(&profileDir, "profileDir", defaultProfileDir(), "directory of additional <name>.yml profiles")
 	flag.Parse()
 
+	// remember the flags given on the command line so that they can win
+	// over the profile and config file
+	explicitFlags := map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		explicitFlags[f.Name] = f.Value.String()
+	})
+
+	if backend != "" {
+		err := transformer.UseBackend(backend)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+
+	err := loadProfiles(profileDir)
+	if err != nil {
+		log.Fatalf("loading profiles: %s", err)
+	}
+	err = transformer.ApplyProfile(profile)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	if configPath != "" {
+		err = loadConfig(transformer, configPath)
+		if err != nil {
+			log.Fatalf("loading config: %s", err)
+		}
+	}
+	if backend != "" && transformer.Backend != backend {
+		err = transformer.UseBackend(backend)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+	for name, value := range explicitFlags {
+		flag.Set(name, value)
+	}
+
+	for list, patterns := range map[*[]string]string{
+		&transformer.Cells.IncludeJobs:  includeJobs,
+		&transformer.Cells.ExcludeJobs:  excludeJobs,
+		&transformer.Cells.IncludeZones: includeZones,
+		&transformer.Cells.ExcludeZones: excludeZones,
+		&transformer.Cells.IncludeAZs:   includeAZs,
+		&transformer.Cells.ExcludeAZs:   excludeAZs,
+	} {
+		if patterns != "" {
+			*list = splitList(patterns)
+		}
+	}
+
+	if onlySteps != "" {
+		transformer.OnlySteps = splitList(onlySteps)
+	}
+	if skipSteps != "" {
+		transformer.SkipSteps = splitList(skipSteps)
+	}
+
+	if gardenPluginConflict != "" {
+		transformer.GardenNetworkPluginConflict, err = parseGardenPluginConflict(gardenPluginConflict)
+		if err != nil {
+			log.Fatalf("parsing gardenPluginConflict: %s", err)
+		}
+	}
+	policies, err := parsePropertyConflicts(propertyConflicts)
+	if err != nil {
+		log.Fatalf("parsing propertyConflicts: %s", err)
+	}
+	for tree, policy := range policies {
+		if transformer.PropertyConflicts == nil {
+			transformer.PropertyConflicts = map[string]ducatify.ConflictPolicy{}
+		}
+		transformer.PropertyConflicts[tree] = policy
+	}
+
+	err = transformer.Check()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
 	if diegoManifestPath == "" {
 		log.Fatalf("missing required flag 'diego'")
 	}
 
-	if cfCredsPath == "" {
+	if cfCredsPath == "" && !migrate {
 		log.Fatalf("missing required flag 'cfCreds'")
 	}
+	if migrate && rolloutDir != "" {
+		log.Fatalf("flags 'migrate' and 'rollout' are mutually exclusive")
+	}
 
-	vanillaBytes, err := ioutil.ReadFile(diegoManifestPath)
-	if err != nil {
-		log.Fatalf("reading diego manifest: %s", err)
+	if inPlace && outputPath != "" {
+		log.Fatalf("flags 'o' and 'in-place' are mutually exclusive")
+	}
+	if rolloutDir != "" && (inPlace || outputPath != "") {
+		log.Fatalf("flag 'rollout' cannot be combined with 'o' or 'in-place'")
+	}
+	if inPlace {
+		outputPath = diegoManifestPath
 	}
 
-	cfCredBytes, err := ioutil.ReadFile(cfCredsPath)
-	if err != nil {
-		log.Fatalf("reading cf creds config: %s", err)
+	var output *outputFile
+	var vanillaBytes []byte
+	if outputPath != "" {
+		output, err = openOutputFile(outputPath)
+		if err != nil {
+			log.Fatalf("reading output file: %s", err)
+		}
+	}
+	if inPlace {
+		if !output.exists {
+			log.Fatalf("reading diego manifest: %s does not exist", diegoManifestPath)
+		}
+		vanillaBytes = output.original
+	} else {
+		vanillaBytes, err = ioutil.ReadFile(diegoManifestPath)
+		if err != nil {
+			log.Fatalf("reading diego manifest: %s", err)
+		}
+	}
+
+	var cfCredBytes []byte
+	if !migrate {
+		cfCredBytes, err = ioutil.ReadFile(cfCredsPath)
+		if err != nil {
+			log.Fatalf("reading cf creds config: %s", err)
+		}
+	}
+
+	transformer.Warn = func(msg string) {
+		log.Printf("warning: %s", msg)
+	}
+	if verbose {
+		transformer.Log = func(msg string) {
+			log.Printf("%s", msg)
+		}
+	}
+
+	if rolloutDir != "" {
+		err = writeRollout(transformer, rolloutDir, diegoManifestPath, vanillaBytes, cfCredBytes)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		return
 	}
 
-	transformedBytes, err := transformBytes(vanillaBytes, cfCredBytes)
+	var transformedBytes []byte
+	if migrate {
+		transformedBytes, err = migrateBytes(transformer, vanillaBytes)
+	} else {
+		transformedBytes, err = transformBytes(transformer, vanillaBytes, cfCredBytes)
+	}
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
 
-	os.Stdout.Write(transformedBytes)
+	if output == nil {
+		os.Stdout.Write(transformedBytes)
+		return
+	}
+
+	err = output.Write(transformedBytes)
+	if err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
 }
 
 func getSystemDomain(cfCreds map[interface{}]interface{}) (string, error) {
@@ -61,34 +272,143 @@ func getSystemDomain(cfCreds map[interface{}]interface{}) (string, error) {
 	return strings.TrimPrefix(api, "api."), nil
 }
 
-func transformBytes(vanillaBytes, cfCredBytes []byte) ([]byte, error) {
+func parsePropertyConflicts(spec string) (map[string]ducatify.ConflictPolicy, error) {
+	policies := map[string]ducatify.ConflictPolicy{}
+	if spec == "" {
+		return policies, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		trees := ducatify.PropertyTrees
+		policyName := entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			trees = []string{entry[:i]}
+			policyName = entry[i+1:]
+			if !isPropertyTree(trees[0]) {
+				return nil, fmt.Errorf("unknown property tree %q", trees[0])
+			}
+		}
+
+		policy := ducatify.ConflictPolicy(policyName)
+		switch policy {
+		case ducatify.ConflictOverwrite, ducatify.ConflictMerge, ducatify.ConflictFail:
+		default:
+			return nil, fmt.Errorf("unknown policy %q", policyName)
+		}
+
+		for _, tree := range trees {
+			policies[tree] = policy
+		}
+	}
+	return policies, nil
+}
+
+func parseGardenPluginConflict(name string) (ducatify.ConflictPolicy, error) {
+	policy := ducatify.ConflictPolicy(name)
+	switch policy {
+	case ducatify.ConflictWarn, ducatify.ConflictFail:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown policy %q, expected warn or fail", name)
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+func isPropertyTree(name string) bool {
+	for _, tree := range ducatify.PropertyTrees {
+		if tree == name {
+			return true
+		}
+	}
+	return false
+}
+
+func parseInputs(vanillaBytes, cfCredBytes []byte) (map[interface{}]interface{}, map[interface{}]interface{}, string, error) {
 	var manifest map[interface{}]interface{}
 	err := candiedyaml.Unmarshal(vanillaBytes, &manifest)
 	if err != nil {
-		return nil, fmt.Errorf("unmarshalling yaml: %s", err)
+		return nil, nil, "", fmt.Errorf("unmarshalling yaml: %s", err)
 	}
 
 	var cfCreds map[interface{}]interface{}
 	err = candiedyaml.Unmarshal(cfCredBytes, &cfCreds)
 	if err != nil {
-		return nil, fmt.Errorf("unmarshalling yaml: %s", err)
+		return nil, nil, "", fmt.Errorf("unmarshalling yaml: %s", err)
 	}
 
 	systemDomain, err := getSystemDomain(cfCreds)
 	if err != nil {
-		return nil, fmt.Errorf("getting system domain: %s", err)
+		return nil, nil, "", fmt.Errorf("getting system domain: %s", err)
 	}
 
-	transformer := ducatify.New()
-	err = transformer.Transform(manifest, cfCreds, systemDomain)
+	return manifest, cfCreds, systemDomain, nil
+}
+
+func transformBytes(transformer *ducatify.Transformer, vanillaBytes, cfCredBytes []byte) ([]byte, error) {
+	manifest, cfCreds, systemDomain, err := parseInputs(vanillaBytes, cfCredBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	setProvenance(transformer, vanillaBytes, cfCredBytes)
+	transformed, err := transformer.Transform(manifest, cfCreds, systemDomain)
 	if err != nil {
 		return nil, fmt.Errorf("transforming: %s", err)
 	}
 
-	transformedBytes, err := candiedyaml.Marshal(manifest)
+	transformedBytes, err := candiedyaml.Marshal(transformed)
 	if err != nil {
 		return nil, fmt.Errorf("re-marshalling yaml: %s", err)
 	}
 
 	return transformedBytes, nil
 }
+
+func migrateBytes(transformer *ducatify.Transformer, manifestBytes []byte) ([]byte, error) {
+	var manifest map[interface{}]interface{}
+	err := candiedyaml.Unmarshal(manifestBytes, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling yaml: %s", err)
+	}
+
+	migrated, applied, err := transformer.Migrate(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("migrating: %s", err)
+	}
+	if len(applied) == 0 {
+		log.Printf("manifest is up to date")
+	}
+	for _, description := range applied {
+		log.Printf("migrated: %s", description)
+	}
+
+	migratedBytes, err := candiedyaml.Marshal(migrated)
+	if err != nil {
+		return nil, fmt.Errorf("re-marshalling yaml: %s", err)
+	}
+	return migratedBytes, nil
+}
+
+// setProvenance makes the transformer record the inputs in the manifest.
+func setProvenance(transformer *ducatify.Transformer, vanillaBytes, cfCredBytes []byte) {
+	transformer.Provenance = &ducatify.Provenance{
+		InputSHA256: sha256Hex(vanillaBytes),
+		CredsSHA256: sha256Hex(cfCredBytes),
+	}
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func printVersion() {
+	fmt.Printf("ducatify %s\n", ducatify.Version)
+	fmt.Printf("commit: %s\n", ducatify.Commit)
+	fmt.Printf("go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
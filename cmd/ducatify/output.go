@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// outputFile is a file that ducatify will replace with a transformed
+// manifest. It remembers what the file held when it was first read so that
+// concurrent edits are not clobbered.
+type outputFile struct {
+	path     string
+	original []byte
+	exists   bool
+	mode     os.FileMode
+}
+
+func openOutputFile(path string) (*outputFile, error) {
+	out := &outputFile{path: path, mode: 0644}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out.original, err = ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out.exists = true
+	out.mode = info.Mode().Perm()
+	return out, nil
+}
+
+// Write backs up the original file, if any, and then atomically replaces it
+// with contents by renaming a temp file into place.
+func (o *outputFile) Write(contents []byte) error {
+	err := o.checkUnchanged()
+	if err != nil {
+		return err
+	}
+
+	if o.exists {
+		err = o.writeBackup()
+		if err != nil {
+			return fmt.Errorf("writing backup: %s", err)
+		}
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(o.path), "."+filepath.Base(o.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %s", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(contents)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing temp file: %s", err)
+	}
+
+	err = os.Chmod(tempFile.Name(), o.mode)
+	if err != nil {
+		return fmt.Errorf("setting permissions on temp file: %s", err)
+	}
+
+	err = os.Rename(tempFile.Name(), o.path)
+	if err != nil {
+		return fmt.Errorf("replacing %s: %s", o.path, err)
+	}
+	return nil
+}
+
+// writeBackup saves the original contents next to the file, named after
+// the current time. It never replaces an existing backup: when one from the
+// same second exists it adds a counter to the name.
+func (o *outputFile) writeBackup() error {
+	base := fmt.Sprintf("%s.%s", o.path, time.Now().UTC().Format("20060102T150405Z"))
+	for i := 0; ; i++ {
+		backupPath := base + ".bak"
+		if i > 0 {
+			backupPath = fmt.Sprintf("%s.%d.bak", base, i)
+		}
+
+		backup, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, o.mode)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = backup.Write(o.original)
+		if err == nil {
+			err = backup.Sync()
+		}
+		closeErr := backup.Close()
+		if err == nil {
+			err = closeErr
+		}
+		return err
+	}
+}
+
+func (o *outputFile) checkUnchanged() error {
+	current, err := ioutil.ReadFile(o.path)
+	switch {
+	case os.IsNotExist(err):
+		if o.exists {
+			return fmt.Errorf("%s was removed since it was read, refusing to overwrite", o.path)
+		}
+		return nil
+	case err != nil:
+		return err
+	case !o.exists:
+		return fmt.Errorf("%s was created since ducatify started, refusing to overwrite", o.path)
+	case !bytes.Equal(current, o.original):
+		return fmt.Errorf("%s changed since it was read, refusing to overwrite", o.path)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify"
+)
+
+const rolloutPlanFile = "plan.txt"
+
+// writeRollout writes every stage of the rollout to a numbered manifest in
+// dir, along with a plan describing the stages in deploy order. The stages
+// and plan of an earlier rollout in dir are removed first, so that the
+// directory only ever holds the stages the plan lists.
+func writeRollout(transformer *ducatify.Transformer, dir, diegoManifestPath string, vanillaBytes, cfCredBytes []byte) error {
+	manifest, cfCreds, systemDomain, err := parseInputs(vanillaBytes, cfCredBytes)
+	if err != nil {
+		return err
+	}
+
+	setProvenance(transformer, vanillaBytes, cfCredBytes)
+	stages, err := transformer.Rollout(manifest, cfCreds, systemDomain)
+	if err != nil {
+		return fmt.Errorf("planning rollout: %s", err)
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("creating rollout directory: %s", err)
+	}
+	err = clearRollout(dir)
+	if err != nil {
+		return fmt.Errorf("removing earlier rollout: %s", err)
+	}
+
+	plan := &bytes.Buffer{}
+	fmt.Fprintf(plan, "ducati rollout plan for %s\n\n", diegoManifestPath)
+	fmt.Fprintf(plan, "Deploy the stages in order, making sure each deploy succeeds before moving on.\n\n")
+
+	for i, stage := range stages {
+		fileName := fmt.Sprintf("%02d-%s.yml", i+1, stage.Name)
+
+		stageBytes, err := candiedyaml.Marshal(stage.Manifest)
+		if err != nil {
+			return fmt.Errorf("marshalling stage %s: %s", stage.Name, err)
+		}
+		err = writeRolloutFile(filepath.Join(dir, fileName), stageBytes)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(plan, "%d. %s\n   %s\n", i+1, fileName, stage.Description)
+	}
+
+	return writeRolloutFile(filepath.Join(dir, rolloutPlanFile), plan.Bytes())
+}
+
+// clearRollout removes the numbered stage manifests and the plan that an
+// earlier rollout wrote to dir, leaving any other files alone.
+func clearRollout(dir string) error {
+	stagePaths, err := filepath.Glob(filepath.Join(dir, "[0-9][0-9]-*.yml"))
+	if err != nil {
+		return err
+	}
+	for _, path := range append(stagePaths, filepath.Join(dir, rolloutPlanFile)) {
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRolloutFile(path string, contents []byte) error {
+	output, err := openOutputFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %s", path, err)
+	}
+	err = output.Write(contents)
+	if err != nil {
+		return fmt.Errorf("writing %s: %s", path, err)
+	}
+	return nil
+}
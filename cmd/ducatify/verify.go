@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify"
+)
+
+func runVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	backend := flags.String("backend", "", "container networking backend the manifest should have (default ducati)")
+	profile := flags.String("profile", "bosh-lite", "environment profile the manifest was transformed with")
+	profileDir := flags.String("profileDir", defaultProfileDir(), "directory of additional <name>.yml profiles")
+	configPath := flags.String("config", "", "yaml file of transformer settings the manifest was transformed with")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s verify [flags] <manifest.yml>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	transformer, err := verifyTransformer(*backend, *profile, *profileDir, *configPath)
+	if err != nil {
+		log.Printf("%s", err)
+		return 1
+	}
+
+	manifestBytes, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		log.Printf("reading manifest: %s", err)
+		return 1
+	}
+	var manifest map[interface{}]interface{}
+	err = candiedyaml.Unmarshal(manifestBytes, &manifest)
+	if err != nil {
+		log.Printf("unmarshalling yaml: %s", err)
+		return 1
+	}
+
+	results, err := transformer.Verify(manifest)
+	if err != nil {
+		log.Printf("verifying: %s", err)
+		return 1
+	}
+
+	failed := printChecklist(os.Stdout, results)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// verifyTransformer sets up a Transformer with the settings the manifest
+// is expected to have been transformed with, applied in the same order as
+// for a transformation.
+func verifyTransformer(backend, profile, profileDir, configPath string) (*ducatify.Transformer, error) {
+	transformer := ducatify.New()
+	if backend != "" {
+		err := transformer.UseBackend(backend)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := loadProfiles(profileDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading profiles: %s", err)
+	}
+	err = transformer.ApplyProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if configPath != "" {
+		err = loadConfig(transformer, configPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading config: %s", err)
+		}
+	}
+	if backend != "" && transformer.Backend != backend {
+		err = transformer.UseBackend(backend)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return transformer, transformer.Check()
+}
+
+func printChecklist(out io.Writer, results []ducatify.VerifyResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.Passed {
+			fmt.Fprintf(out, "[PASS] %s\n", result.Check)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "[FAIL] %s: %s\n", result.Check, result.Detail)
+	}
+
+	fmt.Fprintf(out, "\n%d passed, %d failed\n", len(results)-failed, failed)
+	return failed
+}
@@ -0,0 +1,195 @@
+package ducatify
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// CNIConfig describes the CNI network configuration list that the ducati
+// job renders into adapter.json. It is only written to the manifest when
+// at least one plugin is given, leaving the job's own default otherwise.
+type CNIConfig struct {
+	CNIVersion string `yaml:"cni_version"`
+	Name       string `yaml:"name"`
+
+	// MTU is set on the first plugin, which creates the container's
+	// interface, unless its Args already name one.
+	MTU int `yaml:"mtu"`
+
+	Plugins []CNIPlugin `yaml:"plugins"`
+}
+
+// CNIPlugin is one entry of a CNI plugin chain. Args holds any further
+// plugin-specific keys, which are passed through as they are.
+type CNIPlugin struct {
+	Type string                 `yaml:"type"`
+	IPAM *CNIIPAM               `yaml:"ipam"`
+	Args map[string]interface{} `yaml:"args"`
+}
+
+type CNIIPAM struct {
+	Type    string     `yaml:"type"`
+	Subnet  string     `yaml:"subnet"`
+	Gateway string     `yaml:"gateway"`
+	Routes  []CNIRoute `yaml:"routes"`
+}
+
+type CNIRoute struct {
+	Dst string `yaml:"dst"`
+	GW  string `yaml:"gw"`
+}
+
+var (
+	cniVersions      = []string{"0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0", "1.0.0"}
+	cniNetworkName   = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.\-]*$`)
+	cniReservedKeys  = []string{"type", "name", "cniVersion", "ipam", "prevResult", "runtimeConfig"}
+	cniChainVersions = map[string]bool{"0.3.0": true, "0.3.1": true, "0.4.0": true, "1.0.0": true}
+)
+
+func (c CNIConfig) configured() bool {
+	return len(c.Plugins) > 0
+}
+
+// check validates the configuration against the parts of the CNI spec that
+// can be checked without running the plugins.
+func (c CNIConfig) check() error {
+	if !c.configured() {
+		return nil
+	}
+
+	if !containsString(cniVersions, c.CNIVersion) {
+		return fmt.Errorf("unsupported cni_version %q, expected one of %v", c.CNIVersion, cniVersions)
+	}
+	if !cniNetworkName.MatchString(c.Name) {
+		return fmt.Errorf("network name %q must start with a letter or digit and contain only letters, digits, '_', '.' and '-'", c.Name)
+	}
+	if len(c.Plugins) > 1 && !cniChainVersions[c.CNIVersion] {
+		return fmt.Errorf("cni_version %s does not support plugin chains, use 0.3.0 or later", c.CNIVersion)
+	}
+	if c.MTU != 0 && (c.MTU < 68 || c.MTU > 65535) {
+		return fmt.Errorf("mtu %d must be between 68 and 65535", c.MTU)
+	}
+
+	for i, plugin := range c.Plugins {
+		err := plugin.check()
+		if err != nil {
+			return fmt.Errorf("plugin %d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (p CNIPlugin) check() error {
+	if p.Type == "" {
+		return fmt.Errorf("missing type")
+	}
+	if strings.ContainsAny(p.Type, `/\`) {
+		return fmt.Errorf("type %q must be a plugin binary name, not a path", p.Type)
+	}
+	for key := range p.Args {
+		if containsString(cniReservedKeys, key) {
+			return fmt.Errorf("args must not set the reserved key %q", key)
+		}
+	}
+	if p.IPAM != nil {
+		err := p.IPAM.check()
+		if err != nil {
+			return fmt.Errorf("ipam: %s", err)
+		}
+	}
+	return nil
+}
+
+func (i CNIIPAM) check() error {
+	if i.Type == "" {
+		return fmt.Errorf("missing type")
+	}
+
+	var subnet *net.IPNet
+	if i.Subnet != "" {
+		var err error
+		_, subnet, err = net.ParseCIDR(i.Subnet)
+		if err != nil {
+			return fmt.Errorf("parsing subnet: %s", err)
+		}
+	}
+	if i.Gateway != "" {
+		gateway := net.ParseIP(i.Gateway)
+		if gateway == nil {
+			return fmt.Errorf("gateway %q is not an IP address", i.Gateway)
+		}
+		if subnet != nil && !subnet.Contains(gateway) {
+			return fmt.Errorf("gateway %s is outside subnet %s", gateway, subnet)
+		}
+	}
+	for _, route := range i.Routes {
+		if _, _, err := net.ParseCIDR(route.Dst); err != nil {
+			return fmt.Errorf("parsing route destination: %s", err)
+		}
+		if route.GW != "" && net.ParseIP(route.GW) == nil {
+			return fmt.Errorf("route gateway %q is not an IP address", route.GW)
+		}
+	}
+	return nil
+}
+
+// render returns the configuration list in the CNI spec's own layout, so
+// that the job template can write it out as JSON unchanged.
+func (c CNIConfig) render() map[interface{}]interface{} {
+	plugins := []interface{}{}
+	for i, plugin := range c.Plugins {
+		rendered := map[interface{}]interface{}{}
+		for key, val := range plugin.Args {
+			rendered[key] = deepCopy(val)
+		}
+		rendered["type"] = plugin.Type
+		if i == 0 && c.MTU != 0 {
+			if _, ok := rendered["mtu"]; !ok {
+				rendered["mtu"] = c.MTU
+			}
+		}
+		if plugin.IPAM != nil {
+			rendered["ipam"] = plugin.IPAM.render()
+		}
+		plugins = append(plugins, rendered)
+	}
+
+	return map[interface{}]interface{}{
+		"cniVersion": c.CNIVersion,
+		"name":       c.Name,
+		"plugins":    plugins,
+	}
+}
+
+func (i CNIIPAM) render() map[interface{}]interface{} {
+	rendered := map[interface{}]interface{}{"type": i.Type}
+	if i.Subnet != "" {
+		rendered["subnet"] = i.Subnet
+	}
+	if i.Gateway != "" {
+		rendered["gateway"] = i.Gateway
+	}
+	if len(i.Routes) > 0 {
+		routes := []interface{}{}
+		for _, route := range i.Routes {
+			rendered := map[interface{}]interface{}{"dst": route.Dst}
+			if route.GW != "" {
+				rendered["gw"] = route.GW
+			}
+			routes = append(routes, rendered)
+		}
+		rendered["routes"] = routes
+	}
+	return rendered
+}
+
+func containsString(list []string, s string) bool {
+	for _, el := range list {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CNI config", func() {
+	var (
+		manifest    map[interface{}]interface{}
+		transformer *ducatify.Transformer
+	)
+
+	transform := func() error {
+		transformed, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		if err == nil {
+			manifest = transformed
+		}
+		return err
+	}
+
+	ducatiProperties := func() map[interface{}]interface{} {
+		return manifest["properties"].(map[interface{}]interface{})["ducati"].(map[interface{}]interface{})
+	}
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		transformer.CNI = ducatify.CNIConfig{
+			CNIVersion: "0.3.1",
+			Name:       "ducati-overlay",
+			MTU:        1450,
+			Plugins: []ducatify.CNIPlugin{
+				{
+					Type: "bridge",
+					IPAM: &ducatify.CNIIPAM{
+						Type:    "host-local",
+						Subnet:  "10.255.1.0/24",
+						Gateway: "10.255.1.1",
+						Routes:  []ducatify.CNIRoute{{Dst: "0.0.0.0/0"}},
+					},
+					Args: map[string]interface{}{"isGateway": true},
+				},
+				{
+					Type: "portmap",
+					Args: map[string]interface{}{"capabilities": map[interface{}]interface{}{"portMappings": true}},
+				},
+			},
+		}
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "templates": []interface{}{}},
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	It("renders the plugin chain into the ducati properties", func() {
+		Expect(transform()).To(Succeed())
+		Expect(ducatiProperties()["cni"]).To(Equal(map[interface{}]interface{}{
+			"cniVersion": "0.3.1",
+			"name":       "ducati-overlay",
+			"plugins": []interface{}{
+				map[interface{}]interface{}{
+					"type":      "bridge",
+					"isGateway": true,
+					"mtu":       1450,
+					"ipam": map[interface{}]interface{}{
+						"type":    "host-local",
+						"subnet":  "10.255.1.0/24",
+						"gateway": "10.255.1.1",
+						"routes": []interface{}{
+							map[interface{}]interface{}{"dst": "0.0.0.0/0"},
+						},
+					},
+				},
+				map[interface{}]interface{}{
+					"type":         "portmap",
+					"capabilities": map[interface{}]interface{}{"portMappings": true},
+				},
+			},
+		}))
+	})
+
+	It("leaves the adapter config to the job when no plugins are given", func() {
+		transformer.CNI = ducatify.CNIConfig{}
+		Expect(transform()).To(Succeed())
+		Expect(ducatiProperties()).NotTo(HaveKey("cni"))
+	})
+
+	Context("when the config breaks the CNI spec", func() {
+		itRejects := func(description string, breakConfig func(c *ducatify.CNIConfig), message string) {
+			It("rejects "+description+" before touching the manifest", func() {
+				breakConfig(&transformer.CNI)
+				Expect(transform()).To(MatchError(ContainSubstring(message)))
+				Expect(manifest["releases"]).To(BeEmpty())
+			})
+		}
+
+		itRejects("unknown version", func(c *ducatify.CNIConfig) { c.CNIVersion = "0.9.0" }, `unsupported cni_version "0.9.0"`)
+		itRejects("bad network name", func(c *ducatify.CNIConfig) { c.Name = "-overlay" }, `network name "-overlay"`)
+		itRejects("chain on an old version", func(c *ducatify.CNIConfig) { c.CNIVersion = "0.2.0" }, "does not support plugin chains")
+		itRejects("mtu out of range", func(c *ducatify.CNIConfig) { c.MTU = 10 }, "mtu 10 must be between 68 and 65535")
+		itRejects("missing type", func(c *ducatify.CNIConfig) { c.Plugins[1].Type = "" }, "plugin 2: missing type")
+		itRejects("plugin path", func(c *ducatify.CNIConfig) { c.Plugins[0].Type = "/bin/bridge" }, "must be a plugin binary name")
+		itRejects("reserved key", func(c *ducatify.CNIConfig) { c.Plugins[1].Args["cniVersion"] = "1.0.0" }, `reserved key "cniVersion"`)
+		itRejects("bad subnet", func(c *ducatify.CNIConfig) { c.Plugins[0].IPAM.Subnet = "10.255.1.0" }, "ipam: parsing subnet")
+		itRejects("gateway outside subnet", func(c *ducatify.CNIConfig) { c.Plugins[0].IPAM.Gateway = "10.0.0.1" }, "gateway 10.0.0.1 is outside subnet")
+		itRejects("bad route", func(c *ducatify.CNIConfig) { c.Plugins[0].IPAM.Routes[0].Dst = "default" }, "parsing route destination")
+	})
+})
@@ -0,0 +1,107 @@
+package ducatify
+
+import (
+	"fmt"
+	"sort"
+)
+
+// gardenDNSServers returns the DNS servers that containers on the cells
+// should use. Unless disabled, they are taken from the dns entries of the
+// subnets that the cell jobs are placed on and from the recursors of their
+// consul agents, falling back to GardenDNSServers when the manifest names
+// none. Cells with their own DNS servers in GardenOverrides are ignored.
+func (t *Transformer) gardenDNSServers(manifest *Manifest) ([]string, error) {
+	if !t.DeriveGardenDNSServers {
+		return t.GardenDNSServers, nil
+	}
+
+	globalRecursors, err := lookupProperty(manifest.Properties, "consul.agent.dns_config.recursors")
+	if err != nil {
+		return nil, err
+	}
+
+	cells, _, err := t.ducatiCells(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	serversByZone := map[string][]string{}
+	for _, job := range cells {
+		if t.GardenOverrides[job.Name].DNSServers != nil {
+			continue
+		}
+
+		jobServers := []string{}
+		for _, jobNetwork := range job.Networks {
+			network := manifest.Network(jobNetwork.Name)
+			if network == nil {
+				return nil, fmt.Errorf("job %s uses undefined network %v", job.Name, jobNetwork.Name)
+			}
+			for _, subnet := range network.Subnets {
+				jobServers, _ = mergeStringList(jobServers, subnet.DNS)
+			}
+		}
+
+		recursors := globalRecursors
+		jobRecursors, err := lookupProperty(job.Properties, "consul.agent.dns_config.recursors")
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %s", job.Name, err)
+		}
+		if jobRecursors != nil {
+			recursors = jobRecursors
+		}
+		recursorServers, err := mergeStringList(recursors, nil)
+		if err != nil {
+			return nil, fmt.Errorf("job %s consul recursors: %s", job.Name, err)
+		}
+		jobServers, _ = mergeStringList(jobServers, recursorServers)
+
+		zone, err := cellZone(manifest, job)
+		if err != nil {
+			return nil, err
+		}
+		serversByZone[zone], _ = mergeStringList(serversByZone[zone], jobServers)
+	}
+
+	zones := []string{}
+	for zone := range serversByZone {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	servers := []string{}
+	for i, zone := range zones {
+		if i > 0 && !sameStrings(serversByZone[zones[0]], serversByZone[zone]) {
+			t.warnf("cells in zone %s resolve to DNS servers %v but cells in zone %s resolve to %v",
+				zones[0], serversByZone[zones[0]], zone, serversByZone[zone])
+		}
+		servers, _ = mergeStringList(servers, serversByZone[zone])
+	}
+
+	if len(servers) == 0 {
+		return t.GardenDNSServers, nil
+	}
+	return servers, nil
+}
+
+// cellZone returns the diego zone of a cell job, or its name when it has
+// none configured.
+func cellZone(manifest *Manifest, job *Job) (string, error) {
+	zone, err := jobZone(manifest, job)
+	if zone == "" {
+		return job.Name, err
+	}
+	return zone, err
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
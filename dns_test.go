@@ -0,0 +1,114 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deriving garden DNS servers", func() {
+	var (
+		manifest    map[interface{}]interface{}
+		transformer *ducatify.Transformer
+		warnings    []string
+	)
+
+	network := func(name string, dns ...interface{}) map[interface{}]interface{} {
+		return map[interface{}]interface{}{
+			"name": name,
+			"subnets": []interface{}{
+				map[interface{}]interface{}{"range": "10.0.0.0/24", "dns": dns},
+			},
+		}
+	}
+
+	gardenDNSServers := func() interface{} {
+		return manifest["properties"].(map[interface{}]interface{})["garden"].(map[interface{}]interface{})["dns_servers"]
+	}
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		recordWarnings(transformer, &warnings)
+
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "templates": []interface{}{}},
+				cellJob("cell_z1", "z1", "diego1"),
+				cellJob("cell_z2", "z2", "diego2"),
+			},
+			"networks": []interface{}{
+				network("diego1", "10.0.0.2"),
+				network("diego2", "10.0.0.2"),
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	transform := func() error {
+		transformed, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		if err == nil {
+			manifest = transformed
+		}
+		return err
+	}
+
+	It("uses the dns entries of the subnets the cells are on", func() {
+		Expect(transform()).To(Succeed())
+		Expect(gardenDNSServers()).To(Equal([]string{"10.0.0.2"}))
+		Expect(warnings).To(BeEmpty())
+	})
+
+	It("adds the recursors of the consul agents", func() {
+		manifest["properties"].(map[interface{}]interface{})["consul"] = map[interface{}]interface{}{
+			"agent": map[interface{}]interface{}{
+				"dns_config": map[interface{}]interface{}{
+					"recursors": []interface{}{"10.0.0.3"},
+				},
+			},
+		}
+
+		Expect(transform()).To(Succeed())
+		Expect(gardenDNSServers()).To(Equal([]string{"10.0.0.2", "10.0.0.3"}))
+	})
+
+	It("warns when cells in different zones resolve to different servers", func() {
+		manifest["networks"] = []interface{}{
+			network("diego1", "10.0.0.2"),
+			network("diego2", "10.0.1.2"),
+		}
+
+		Expect(transform()).To(Succeed())
+		Expect(gardenDNSServers()).To(Equal([]string{"10.0.0.2", "10.0.1.2"}))
+		Expect(warnings).To(ConsistOf(
+			"cells in zone z1 resolve to DNS servers [10.0.0.2] but cells in zone z2 resolve to [10.0.1.2]",
+		))
+	})
+
+	It("falls back to the configured servers when the manifest names none", func() {
+		manifest["networks"] = []interface{}{network("diego1"), network("diego2")}
+
+		Expect(transform()).To(Succeed())
+		Expect(gardenDNSServers()).To(Equal([]string{"192.168.255.254"}))
+	})
+
+	It("uses only the configured servers when derivation is disabled", func() {
+		transformer.DeriveGardenDNSServers = false
+
+		Expect(transform()).To(Succeed())
+		Expect(gardenDNSServers()).To(Equal([]string{"192.168.255.254"}))
+	})
+
+	It("returns an error when a cell uses an undefined network", func() {
+		manifest["networks"] = []interface{}{network("diego1", "10.0.0.2")}
+
+		Expect(transform()).To(MatchError(ContainSubstring("job cell_z2 uses undefined network diego2")))
+	})
+})
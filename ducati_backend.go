@@ -0,0 +1,314 @@
+package ducatify
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/ducatify/manifestpath"
+)
+
+// ServiceDiscovery selects how the ducati daemons find the ducati database.
+type ServiceDiscovery string
+
+const (
+	// ServiceDiscoveryConsul registers the database as a consul service
+	// through a consul_agent on the ducati_db job.
+	ServiceDiscoveryConsul ServiceDiscovery = "consul"
+
+	// ServiceDiscoveryBOSHDNS has the ducati_db job provide a BOSH link
+	// with a BOSH DNS alias for the database.
+	ServiceDiscoveryBOSHDNS ServiceDiscovery = "bosh-dns"
+)
+
+const consulDBHost = "ducati-db.service.cf.internal"
+
+// ducatiBackend adds ducati with a postgres-backed ducati_db job and connet
+// on the cc_bridges.
+type ducatiBackend struct{}
+
+func (ducatiBackend) Defaults(t *Transformer) {
+	t.GardenSharedMounts = []string{"/var/vcap/data/ducati/container-netns"}
+	t.GardenNetworkPlugin = "/var/vcap/packages/ducati/bin/guardian-cni-adapter"
+	t.GardenNetworkPluginExtraArgs = []string{"--configFile=/var/vcap/jobs/ducati/config/adapter.json"}
+	t.NsyncNetworkID = "ducati-overlay"
+}
+
+func (ducatiBackend) Releases(t *Transformer) []*Release {
+	return []*Release{{Name: "ducati", Version: t.ReleaseVersion}}
+}
+
+func (ducatiBackend) AddJobs(t *Transformer, manifest *Manifest) error {
+	if t.ExternalDBHost != "" {
+		return nil
+	}
+
+	postgresTemplate := &Template{Name: "postgres", Release: "ducati"}
+	ducatiDBJob := &Job{
+		Name:           "ducati_db",
+		Instances:      1,
+		PersistentDisk: t.DBPersistentDisk,
+		ResourcePool:   t.DBResourcePool,
+		Networks:       []*JobNetwork{{Name: t.DBNetwork}},
+		Templates:      []*Template{postgresTemplate},
+	}
+
+	switch t.ServiceDiscovery {
+	case ServiceDiscoveryConsul:
+		ducatiDBJob.Templates = append(ducatiDBJob.Templates, &Template{Name: "consul_agent", Release: "cf"})
+		ducatiDBJob.Properties = map[interface{}]interface{}{
+			"consul": map[interface{}]interface{}{
+				"agent": map[interface{}]interface{}{
+					"services": map[interface{}]interface{}{
+						"ducati-db": map[interface{}]interface{}{
+							"name": "ducati-db",
+							"check": map[interface{}]interface{}{
+								"script":   "/bin/true",
+								"interval": "5s",
+							},
+						},
+					},
+				},
+			},
+		}
+	case ServiceDiscoveryBOSHDNS:
+		postgresTemplate.Extra = map[interface{}]interface{}{
+			"provides": map[interface{}]interface{}{
+				"database": map[interface{}]interface{}{
+					"as": "ducati_db",
+					"aliases": []interface{}{
+						map[interface{}]interface{}{"domain": t.DBAlias},
+					},
+				},
+			},
+		}
+	}
+
+	newJobs := []*Job{}
+	for _, job := range manifest.Jobs {
+		newJobs = append(newJobs, job)
+		if job.Name == "database_z1" {
+			newJobs = append(newJobs, ducatiDBJob)
+		}
+	}
+	if len(newJobs) == len(manifest.Jobs) {
+		return errors.New("database_z1 job not found, don't know where to put the ducati_db job")
+	}
+
+	manifest.Jobs = newJobs
+	return nil
+}
+
+func (b ducatiBackend) ModifyCCBridge(t *Transformer, manifest *Manifest, systemDomain string) error {
+	natsProperties, err := getNatsProperties(manifest)
+	if err != nil {
+		return fmt.Errorf("getting nats properties: %s", err)
+	}
+
+	for _, job := range manifest.Jobs {
+		if !strings.HasPrefix(job.Name, "cc_bridge_z") {
+			continue
+		}
+
+		if job.Properties == nil {
+			job.Properties = map[interface{}]interface{}{}
+		}
+		err = manifestpath.Set(job.Properties, "nats", natsProperties)
+		if err != nil {
+			return fmt.Errorf("job %s: %s", job.Name, err)
+		}
+
+		routes, err := lookupProperty(job.Properties, "route_registrar.routes")
+		if err != nil {
+			return fmt.Errorf("job %s: %s", job.Name, err)
+		}
+		routeList, ok := routes.([]interface{})
+		if !ok && routes != nil {
+			return fmt.Errorf("job %s: expected properties.route_registrar.routes to be a list, got %T", job.Name, routes)
+		}
+		err = manifestpath.Set(job.Properties, "route_registrar.routes", b.mergeConnetRoute(t, routeList, systemDomain))
+		if err != nil {
+			return fmt.Errorf("job %s: properties: %s", job.Name, err)
+		}
+
+		job.appendMissingTemplates(b.connetTemplates()...)
+	}
+	return nil
+}
+
+// mergeConnetRoute replaces any existing route named connet with the one
+// ducatify generates, or appends it when there is none.
+func (ducatiBackend) mergeConnetRoute(t *Transformer, routes []interface{}, systemDomain string) []interface{} {
+	connetRoute := map[interface{}]interface{}{
+		"name":                  "connet",
+		"registration_interval": t.ConnetRegistrationInterval,
+		"port":                  t.ConnetPort,
+		"uris":                  []string{t.ConnetHostnamePrefix + "." + systemDomain},
+	}
+
+	merged := []interface{}{}
+	replaced := false
+	for _, route := range routes {
+		if r, ok := route.(map[interface{}]interface{}); ok && r["name"] == "connet" {
+			merged = append(merged, connetRoute)
+			replaced = true
+			continue
+		}
+		merged = append(merged, route)
+	}
+	if !replaced {
+		merged = append(merged, connetRoute)
+	}
+	return merged
+}
+
+func (ducatiBackend) connetTemplates() []*Template {
+	return []*Template{
+		{Name: "connet", Release: "ducati"},
+		{Name: "route_registrar", Release: "cf"},
+	}
+}
+
+func (b ducatiBackend) CellTemplates(t *Transformer, job string) []*Template {
+	templates := []*Template{{Name: "ducati", Release: "ducati"}}
+	if strings.HasPrefix(job, "colocated") {
+		templates = append(templates, b.connetTemplates()...)
+	}
+	return templates
+}
+
+func (ducatiBackend) PropertyTrees(t *Transformer) []PropertyTree {
+	database := map[interface{}]interface{}{
+		"username": t.DBUsername,
+		"password": t.DBPassword,
+		"name":     t.DBName,
+		"ssl_mode": t.DBSSLMode,
+		"host":     t.dbHost(),
+		"port":     t.DBPort,
+	}
+
+	ducati := map[interface{}]interface{}{
+		"daemon": map[interface{}]interface{}{
+			"overlay_network":      t.OverlayNetwork,
+			"subnet_prefix_length": t.OverlaySubnetPrefixLength,
+			"database":             database,
+		},
+	}
+	if t.ExternalDBHost == "" {
+		// the postgres job on ducati_db creates the database and role
+		ducati["database"] = map[interface{}]interface{}{
+			"db_scheme": "postgres",
+			"port":      t.DBPort,
+			"databases": []interface{}{
+				map[interface{}]interface{}{
+					"name": t.DBName, "tag": "whatever",
+				},
+			},
+			"roles": []interface{}{
+				map[interface{}]interface{}{
+					"name":     t.DBUsername,
+					"password": t.DBPassword,
+					"tag":      "admin",
+				},
+			},
+		}
+	}
+	if t.CNI.configured() {
+		// the ducati job renders this into adapter.json
+		ducati["cni"] = t.CNI.render()
+	}
+
+	return []PropertyTree{
+		{Name: "ducati", Value: ducati},
+		{
+			Name: "connet",
+			Value: map[interface{}]interface{}{
+				"daemon": map[interface{}]interface{}{
+					"database": deepCopy(database),
+				},
+			},
+		},
+	}
+}
+
+// dbHost is the address the daemons connect to the database on.
+func (t *Transformer) dbHost() string {
+	if t.ExternalDBHost != "" {
+		return t.ExternalDBHost
+	}
+	if t.ServiceDiscovery == ServiceDiscoveryBOSHDNS {
+		return t.DBAlias
+	}
+	return consulDBHost
+}
+
+// checkServiceDiscovery makes sure the database will be reachable with the
+// selected ServiceDiscovery.
+func (t *Transformer) checkServiceDiscovery() error {
+	if t.ExternalDBHost != "" {
+		return nil
+	}
+	switch t.ServiceDiscovery {
+	case ServiceDiscoveryConsul:
+		return nil
+	case ServiceDiscoveryBOSHDNS:
+		if t.DBAlias == "" {
+			return fmt.Errorf("%s needs a db_alias for the database", t.ServiceDiscovery)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported service discovery %q, expected %s or %s",
+		t.ServiceDiscovery, ServiceDiscoveryConsul, ServiceDiscoveryBOSHDNS)
+}
+
+func (ducatiBackend) AcceptanceErrand(t *Transformer) (*Job, string) {
+	return &Job{
+		Name:         "ducati-acceptance",
+		Instances:    1,
+		Lifecycle:    "errand",
+		ResourcePool: t.DBResourcePool,
+		Networks:     []*JobNetwork{{Name: t.DBNetwork}},
+		Templates:    []*Template{{Name: "acceptance-with-cf", Release: "ducati"}},
+	}, "acceptance-with-cf"
+}
+
+func (b ducatiBackend) Verify(t *Transformer, manifest *Manifest) ([]VerifyResult, error) {
+	results := []VerifyResult{}
+
+	if t.ExternalDBHost == "" {
+		dbJob := manifest.Job("ducati_db")
+		if dbJob == nil {
+			results = append(results, failed("ducati_db job", "missing"))
+		} else {
+			results = append(results, verifyTemplates(dbJob, []*Template{{Name: "postgres"}}))
+		}
+	}
+
+	bridges := 0
+	for _, job := range manifest.Jobs {
+		if strings.HasPrefix(job.Name, "cc_bridge_z") {
+			bridges++
+			results = append(results, verifyTemplates(job, b.connetTemplates()))
+		}
+	}
+	if bridges == 0 {
+		results = append(results, failed("cc_bridge jobs", "none found"))
+	}
+
+	ducatiDB, err := lookupProperty(manifest.Properties, "ducati.daemon.database")
+	if err != nil {
+		return nil, err
+	}
+	connetDB, err := lookupProperty(manifest.Properties, "connet.daemon.database")
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, verifySameKeys("ducati and connet database credentials agree", ducatiDB, connetDB,
+		"host", "port", "name", "username", "password", "ssl_mode"))
+	return results, nil
+}
+
+func (ducatiBackend) SetupStage() (string, string) {
+	return "ducati-db-and-connet",
+		"add the ducati release, the ducati_db job, connet on the cc_bridges and their properties; no cells change"
+}
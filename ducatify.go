@@ -1,29 +1,110 @@
 package ducatify
 
 import (
-	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/cloudfoundry-incubator/ducatify/manifestpath"
 )
 
 type Transformer struct {
-	ReleaseVersion               string
-	DBPersistentDisk             int
-	DBResourcePool               string
-	DBNetwork                    string
-	GardenSharedMounts           []string
-	GardenNetworkPlugin          string
-	GardenNetworkPluginExtraArgs []string
-	GardenDNSServers             []string
-	DBName                       string
-	DBUsername                   string
-	DBPassword                   string
-	DBSSLMode                    string
-	NsyncNetworkID               string
+	// Backend names the container networking implementation to add, see
+	// BackendNames. Use UseBackend to also pick up the backend's garden
+	// and nsync settings.
+	Backend string `yaml:"backend"`
+
+	ReleaseVersion               string   `yaml:"release_version"`
+	DBPersistentDisk             int      `yaml:"db_persistent_disk"`
+	DBResourcePool               string   `yaml:"db_resource_pool"`
+	DBNetwork                    string   `yaml:"db_network"`
+	GardenSharedMounts           []string `yaml:"garden_shared_mounts"`
+	GardenNetworkPlugin          string   `yaml:"garden_network_plugin"`
+	GardenNetworkPluginExtraArgs []string `yaml:"garden_network_plugin_extra_args"`
+	GardenDNSServers             []string `yaml:"garden_dns_servers"`
+	DBName                       string   `yaml:"db_name"`
+	DBUsername                   string   `yaml:"db_username"`
+	DBPassword                   string   `yaml:"db_password"`
+	DBSSLMode                    string   `yaml:"db_ssl_mode"`
+	NsyncNetworkID               string   `yaml:"nsync_network_id"`
+	ConnetPort                   int      `yaml:"connet_port"`
+	ConnetRegistrationInterval   string   `yaml:"connet_registration_interval"`
+	ConnetHostnamePrefix         string   `yaml:"connet_hostname_prefix"`
+	FlannelEtcdEndpoints         []string `yaml:"flannel_etcd_endpoints"`
+
+	// ServiceDiscovery selects how the daemons find the ducati database.
+	// With ServiceDiscoveryBOSHDNS they connect to DBAlias.
+	ServiceDiscovery ServiceDiscovery `yaml:"service_discovery"`
+	DBAlias          string           `yaml:"db_alias"`
+
+	// ExternalDBHost, if set, is an existing postgres server listening on
+	// DBPort that the daemons use instead of a ducati_db job.
+	ExternalDBHost string `yaml:"external_db_host"`
+	DBPort         int    `yaml:"db_port"`
+
+	// OverlayNetwork is the CIDR that the backend carves container subnets
+	// out of, one subnet of OverlaySubnetPrefixLength bits per cell.
+	OverlayNetwork            string `yaml:"overlay_network"`
+	OverlaySubnetPrefixLength int    `yaml:"overlay_subnet_prefix_length"`
+
+	// CNI describes the plugin chain that the backend's garden network
+	// plugin runs. Only the ducati backend uses it.
+	CNI CNIConfig `yaml:"cni"`
+
+	// GardenOverrides replaces the garden network settings for individual
+	// cell jobs, keyed by job name.
+	GardenOverrides map[string]GardenOverride `yaml:"garden_overrides"`
+
+	// Cells selects the cell jobs that the backend is enabled on. By
+	// default it is enabled on all of them.
+	Cells CellFilter `yaml:"cells"`
+
+	// GardenNetworkPluginConflict decides what happens when the manifest
+	// already configures a different garden network_plugin.
+	GardenNetworkPluginConflict ConflictPolicy `yaml:"garden_network_plugin_conflict"`
+
+	// PropertyConflicts selects, per property tree (see PropertyTrees), how
+	// existing values are handled: ConflictOverwrite (the default),
+	// ConflictMerge or ConflictFail.
+	PropertyConflicts map[string]ConflictPolicy `yaml:"property_conflicts"`
+
+	// DeriveGardenDNSServers makes ducatify take the containers' DNS
+	// servers from the subnets and consul agents of the cell jobs, using
+	// GardenDNSServers only when the manifest names none.
+	DeriveGardenDNSServers bool `yaml:"derive_garden_dns_servers"`
+
+	// OnlySteps, if set, names the only steps Transform runs, and
+	// SkipSteps the steps it leaves out, see StepNames.
+	OnlySteps []string `yaml:"only_steps"`
+	SkipSteps []string `yaml:"skip_steps"`
+
+	// Strict makes Transform fail instead of creating property blocks that
+	// the manifest leaves to job defaults, such as properties.garden.
+	Strict bool `yaml:"strict"`
+
+	// scopeGardenToCells writes garden properties into each ducati cell
+	// job even when every cell has ducati.
+	scopeGardenToCells bool
+
+	// Warn, if set, is called with a message for every non-fatal problem
+	// found while transforming a manifest.
+	Warn func(string) `yaml:"-"`
+
+	// Log, if set, is called with a line of trace output for every step
+	// that Transform runs.
+	Log func(string) `yaml:"-"`
+
+	// Hooks are called as each step starts, finishes or fails.
+	Hooks StepHooks `yaml:"-"`
+
+	// Provenance, if set, is recorded in the transformed manifest.
+	Provenance *Provenance `yaml:"-"`
 }
 
-func New() *Transformer {
-	return &Transformer{
+// New returns a Transformer with the defaults for a bosh-lite deployment of
+// the ducati backend, changed by opts in order.
+func New(opts ...Option) *Transformer {
+	t := &Transformer{
+		Backend:          "ducati",
 		ReleaseVersion:   "latest",
 		DBPersistentDisk: 256,
 		DBResourcePool:   "database_z1",
@@ -33,362 +114,253 @@ func New() *Transformer {
 		DBUsername: "ducati_daemon",
 		DBPassword: "some-password",
 		DBSSLMode:  "disable",
+		DBPort:     5432,
 
-		GardenSharedMounts:           []string{"/var/vcap/data/ducati/container-netns"},
-		GardenNetworkPlugin:          "/var/vcap/packages/ducati/bin/guardian-cni-adapter",
-		GardenNetworkPluginExtraArgs: []string{"--configFile=/var/vcap/jobs/ducati/config/adapter.json"},
-		GardenDNSServers:             []string{"192.168.255.254"},
+		ServiceDiscovery: ServiceDiscoveryConsul,
+		DBAlias:          "ducati-db.ducati.internal",
 
-		NsyncNetworkID: "ducati-overlay",
-	}
-}
+		GardenDNSServers: []string{"192.168.255.254"},
 
-func (t *Transformer) Transform(
-	manifest map[interface{}]interface{},
-	acceptanceJobConfig map[interface{}]interface{},
-	systemDomain string,
-) error {
-	err := t.updateReleases(manifest)
-	if err != nil {
-		return fmt.Errorf("updating releases: %s", err)
-	}
+		ConnetPort:                 4002,
+		ConnetRegistrationInterval: "20s",
+		ConnetHostnamePrefix:       "connet",
 
-	err = t.addDucatiDBJob(manifest)
-	if err != nil {
-		return fmt.Errorf("adding ducati_db job: %s", err)
-	}
+		FlannelEtcdEndpoints: []string{"http://etcd.service.cf.internal:4001"},
 
-	natsProperties, err := getNatsProperties(manifest)
-	if err != nil {
-		return fmt.Errorf("getting nats properties: %s", err)
-	}
+		OverlayNetwork:            "10.255.0.0/16",
+		OverlaySubnetPrefixLength: 24,
 
-	err = t.modifyCCBridgeJob(manifest, systemDomain, natsProperties)
-	if err != nil {
-		return fmt.Errorf("adding connet template to cc_bridge: %s", err)
+		GardenNetworkPluginConflict: ConflictWarn,
+		DeriveGardenDNSServers:      true,
 	}
+	ducatiBackend{}.Defaults(t)
 
-	err = t.modifyCellJob(manifest, "cell_z")
-	if err != nil {
-		return fmt.Errorf("adding ducati template to cells: %s", err)
+	for _, opt := range opts {
+		opt(t)
 	}
+	return t
+}
 
-	err = t.modifyCellJob(manifest, "colocated_z")
+// Transform returns a copy of manifest with the backend added. The
+// manifest itself is left untouched, also when Transform fails. The
+// manifest is decoded and the settings checked first, so a manifest of the
+// wrong shape and bad settings are rejected before any step runs.
+func (t *Transformer) Transform(
+	manifest map[interface{}]interface{},
+	acceptanceJobConfig map[interface{}]interface{},
+	systemDomain string,
+) (map[interface{}]interface{}, error) {
+	m, err := DecodeManifest(manifest)
 	if err != nil {
-		return fmt.Errorf("adding ducati template to colocated vm: %s", err)
+		return nil, fmt.Errorf("decoding manifest: %s", err)
 	}
 
-	err = t.addGardenProperties(manifest)
+	transformed, err := t.TransformManifest(m, acceptanceJobConfig, systemDomain)
 	if err != nil {
-		return fmt.Errorf("adding garden properties: %s", err)
+		return nil, err
 	}
+	return transformed.Map(), nil
+}
 
-	err = t.addNsyncProperties(manifest)
+// TransformManifest is Transform for a decoded Manifest.
+func (t *Transformer) TransformManifest(
+	manifest *Manifest,
+	acceptanceJobConfig map[interface{}]interface{},
+	systemDomain string,
+) (*Manifest, error) {
+	err := t.Check()
 	if err != nil {
-		return fmt.Errorf("adding nsync properties: %s", err)
+		return nil, err
 	}
 
-	err = t.addDucatiProperties(manifest)
+	err = t.checkOverlayNetwork(manifest)
 	if err != nil {
-		return fmt.Errorf("adding ducati properties: %s", err)
+		return nil, fmt.Errorf("checking overlay network: %s", err)
 	}
 
-	err = t.addConnetProperties(manifest)
+	steps, err := t.steps()
 	if err != nil {
-		return fmt.Errorf("adding connet properties: %s", err)
+		return nil, err
 	}
-
-	err = t.addAcceptanceJob(manifest)
+	steps, err = t.selectSteps(steps, manifest)
 	if err != nil {
-		return fmt.Errorf("adding acceptance with cf job: %s", err)
+		return nil, err
 	}
 
-	err = t.addAcceptanceJobProperties(manifest, acceptanceJobConfig)
+	transformed, err := manifest.Copy()
 	if err != nil {
-		return fmt.Errorf("adding acceptance with cf job properties: %s", err)
+		return nil, err
 	}
-	return nil
-}
-
-func dynRecover(context string, err *error) {
-	if e := recover(); e != nil {
-		*err = fmt.Errorf("%s: %+v", context, e)
+	err = t.runSteps(steps, stepInput{
+		manifest:            transformed,
+		acceptanceJobConfig: deepCopy(acceptanceJobConfig).(map[interface{}]interface{}),
+		systemDomain:        systemDomain,
+	})
+	if err != nil {
+		return nil, err
 	}
-}
-
-func (t *Transformer) modifyCCBridgeJob(manifest map[interface{}]interface{}, systemDomain string, natsProperties interface{}) (err error) {
-	defer dynRecover("add ducati template to cc_bridge", &err)
-
-	for _, jobVal := range manifest["jobs"].([]interface{}) {
-		nameVal, err := getElement(jobVal, "name")
-		if err != nil {
-			return err
-		}
-		if !strings.HasPrefix(nameVal.(string), "cc_bridge_z") {
-			continue
-		}
 
-		properties, err := getElement(jobVal, "properties")
-		if err != nil {
-			properties = make(map[interface{}]interface{})
-			err = setElement(jobVal, "properties", properties)
-			if err != nil {
-				return err
-			}
-		}
-		err = setElement(properties, "nats", natsProperties)
-		if err != nil {
-			return err
-		}
-		routeRegistrarProperties := map[interface{}]interface{}{
-			"routes": []interface{}{
-				map[interface{}]interface{}{
-					"name":                  "connet",
-					"registration_interval": "20s",
-					"port":                  4002,
-					"uris":                  []string{"connet." + systemDomain},
-				},
-			},
-		}
-		err = setElement(properties, "route_registrar", routeRegistrarProperties)
+	// a partial run leaves the layout as it was
+	if len(t.OnlySteps) == 0 && len(t.SkipSteps) == 0 {
+		err = setSchemaVersion(transformed, SchemaVersion)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("recording schema version: %s", err)
 		}
-
-		templates, err := getElement(jobVal, "templates")
+	}
+	if t.Provenance != nil {
+		err = t.recordProvenance(transformed)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("recording provenance: %s", err)
 		}
-		templates = append(templates.([]interface{}),
-			map[interface{}]interface{}{"name": "connet", "release": "ducati"},
-			map[interface{}]interface{}{"name": "route_registrar", "release": "cf"},
-		)
+	}
+	return transformed, nil
+}
 
-		err = setElement(jobVal, "templates", templates)
-		if err != nil {
-			return err
+// appendMissingTemplates appends each of toAdd whose name is not already
+// among the job's templates.
+func (j *Job) appendMissingTemplates(toAdd ...*Template) {
+	for _, template := range toAdd {
+		if j.HasTemplate(template.Name) {
+			continue
 		}
+		j.Templates = append(j.Templates, template)
 	}
-	return nil
 }
 
-func (t *Transformer) modifyCellJob(manifest map[interface{}]interface{}, namePrefix string) (err error) {
-	defer dynRecover("add ducati template to "+namePrefix, &err)
+func (t *Transformer) updateReleases(manifest *Manifest, backend Backend) error {
+	manifest.Releases = append(manifest.Releases, backend.Releases(t)...)
+	return nil
+}
 
-	for _, jobVal := range manifest["jobs"].([]interface{}) {
-		nameVal, err := getElement(jobVal, "name")
-		if err != nil {
-			return err
-		}
-		if !strings.HasPrefix(nameVal.(string), namePrefix) {
+func (t *Transformer) modifyCellJob(manifest *Manifest, namePrefix string, backend Backend) error {
+	for _, job := range manifest.Jobs {
+		if !strings.HasPrefix(job.Name, namePrefix) {
 			continue
 		}
-
-		templates, err := getElement(jobVal, "templates")
+		assignment, err := t.Cells.assign(manifest, job)
 		if err != nil {
 			return err
 		}
-		templates = append(templates.([]interface{}),
-			map[interface{}]interface{}{"name": "ducati", "release": "ducati"},
-		)
-		if strings.HasPrefix(nameVal.(string), "colocated") {
-			templates = append(templates.([]interface{}),
-				map[interface{}]interface{}{"name": "connet", "release": "ducati"},
-				map[interface{}]interface{}{"name": "route_registrar", "release": "cf"},
-			)
+		if !assignment.Ducati {
+			t.warnf("%s not enabled on %s: %s", t.Backend, assignment.Job, assignment.Reason)
+			continue
 		}
 
-		err = setElement(jobVal, "templates", templates)
-		if err != nil {
-			return err
-		}
+		job.appendMissingTemplates(backend.CellTemplates(t, job.Name)...)
 	}
-
 	return nil
 }
 
-func (t *Transformer) addAcceptanceJob(manifest map[interface{}]interface{}) (err error) {
-	defer dynRecover("add acceptance with cf job", &err)
-
-	acceptanceJob := map[interface{}]interface{}{
-		"name":          "ducati-acceptance",
-		"instances":     1,
-		"lifecycle":     "errand",
-		"resource_pool": t.DBResourcePool,
-		"networks": []interface{}{
-			map[interface{}]interface{}{
-				"name": t.DBNetwork,
-			},
-		},
-		"templates": []interface{}{
-			map[interface{}]interface{}{"name": "acceptance-with-cf", "release": "ducati"},
-		},
-	}
-
-	oldJobs := manifest["jobs"].([]interface{})
-	manifest["jobs"] = append(oldJobs, acceptanceJob)
-
+func (t *Transformer) addAcceptanceJob(manifest *Manifest, acceptanceJob *Job) error {
+	manifest.Jobs = append(manifest.Jobs, acceptanceJob)
 	return nil
 }
 
-func (t *Transformer) addDucatiDBJob(manifest map[interface{}]interface{}) (err error) {
-	defer dynRecover("add ducati db job", &err)
-
-	ducatiDBJob := map[interface{}]interface{}{
-		"name":            "ducati_db",
-		"instances":       1,
-		"persistent_disk": t.DBPersistentDisk,
-		"resource_pool":   t.DBResourcePool,
-		"networks": []interface{}{
-			map[interface{}]interface{}{
-				"name": t.DBNetwork,
-			},
-		},
-		"templates": []interface{}{
-			map[interface{}]interface{}{"name": "postgres", "release": "ducati"},
-			map[interface{}]interface{}{"name": "consul_agent", "release": "cf"},
-		},
-		"properties": map[interface{}]interface{}{
-			"consul": map[interface{}]interface{}{
-				"agent": map[interface{}]interface{}{
-					"services": map[interface{}]interface{}{
-						"ducati-db": map[interface{}]interface{}{
-							"name": "ducati-db",
-							"check": map[interface{}]interface{}{
-								"script":   "/bin/true",
-								"interval": "5s",
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	oldJobs := manifest["jobs"].([]interface{})
-	newJobs := []interface{}{}
-	for _, job := range oldJobs {
-		newJobs = append(newJobs, job)
-		if job.(map[interface{}]interface{})["name"] == "database_z1" {
-			newJobs = append(newJobs, ducatiDBJob)
-		}
-	}
-	if len(newJobs) == len(oldJobs) {
-		return errors.New("database_z1 job not found, don't know where to put the ducati_db job")
+func (t *Transformer) addPropertyTree(manifest *Manifest, tree PropertyTree) error {
+	props, err := t.ensurePropertyBlock(manifest)
+	if err != nil {
+		return err
 	}
-
-	manifest["jobs"] = newJobs
-
-	return nil
-}
-
-func (t *Transformer) updateReleases(manifest map[interface{}]interface{}) (err error) {
-	defer dynRecover("update releases", &err)
-
-	manifest["releases"] = append(
-		manifest["releases"].([]interface{}),
-		map[interface{}]interface{}{
-			"name":    "ducati",
-			"version": t.ReleaseVersion,
-		})
-	return nil
+	return t.setPropertyTree(props, tree.Name, tree.Value)
 }
 
-func (t *Transformer) addGardenProperties(manifest map[interface{}]interface{}) (err error) {
-	defer dynRecover("add garden properties", &err)
-	gardenProps := manifest["properties"].(map[interface{}]interface{})["garden"].(map[interface{}]interface{})
-	gardenProps["network_plugin"] = t.GardenNetworkPlugin
-	gardenProps["network_plugin_extra_args"] = t.GardenNetworkPluginExtraArgs
-	gardenProps["shared_mounts"] = t.GardenSharedMounts
-	gardenProps["dns_servers"] = t.GardenDNSServers
-	return nil
-}
-
-func (t *Transformer) addNsyncProperties(manifest map[interface{}]interface{}) (err error) {
-	defer dynRecover("add nsync properties", &err)
-	diegoProps := manifest["properties"].(map[interface{}]interface{})["diego"].(map[interface{}]interface{})
-	nsyncProps := diegoProps["nsync"].(map[interface{}]interface{})
+func (t *Transformer) addNsyncProperties(manifest *Manifest) error {
+	nsyncProps, err := t.ensurePropertyBlock(manifest, "diego", "nsync")
+	if err != nil {
+		return err
+	}
 	nsyncProps["network_id"] = t.NsyncNetworkID
-	return nil
-}
 
-func (t *Transformer) addDucatiProperties(manifest map[interface{}]interface{}) (err error) {
-	defer dynRecover("add ducati properties", &err)
-
-	props := manifest["properties"].(map[interface{}]interface{})
-	props["ducati"] = map[interface{}]interface{}{
-		"daemon": map[interface{}]interface{}{
-			"database": map[interface{}]interface{}{
-				"username": t.DBUsername,
-				"password": t.DBPassword,
-				"name":     t.DBName,
-				"ssl_mode": t.DBSSLMode,
-				"host":     "ducati-db.service.cf.internal",
-				"port":     5432,
-			},
-		},
-		"database": map[interface{}]interface{}{
-			"db_scheme": "postgres",
-			"port":      5432,
-			"databases": []interface{}{
-				map[interface{}]interface{}{
-					"name": t.DBName, "tag": "whatever",
-				},
-			},
-			"roles": []interface{}{
-				map[interface{}]interface{}{
-					"name":     t.DBUsername,
-					"password": t.DBPassword,
-					"tag":      "admin",
-				},
-			},
-		},
+	_, partial, err := t.ducatiCells(manifest)
+	if err != nil {
+		return err
+	}
+	if partial {
+		t.warnf("nsync network_id applies to every app, including apps placed on cells without %s", t.Backend)
 	}
-
 	return nil
 }
 
-func (t *Transformer) addConnetProperties(manifest map[interface{}]interface{}) (err error) {
-	defer dynRecover("add connet properties", &err)
-
-	props := manifest["properties"].(map[interface{}]interface{})
-	props["connet"] = map[interface{}]interface{}{
-		"daemon": map[interface{}]interface{}{
-			"database": map[interface{}]interface{}{
-				"username": t.DBUsername,
-				"password": t.DBPassword,
-				"name":     t.DBName,
-				"ssl_mode": t.DBSSLMode,
-				"host":     "ducati-db.service.cf.internal",
-				"port":     5432,
-			},
-		},
+// ensurePropertyBlock returns the map found by following keys from the
+// manifest's global properties, creating any missing maps along the way
+// unless the Transformer is strict.
+func (t *Transformer) ensurePropertyBlock(manifest *Manifest, keys ...string) (map[interface{}]interface{}, error) {
+	missing := func(path string) error {
+		if t.Strict {
+			return fmt.Errorf("manifest has no %s", path)
+		}
+		t.warnf("manifest has no %s, creating it", path)
+		return nil
 	}
 
-	return nil
+	if manifest.Properties == nil {
+		err := missing("properties")
+		if err != nil {
+			return nil, err
+		}
+		manifest.Properties = map[interface{}]interface{}{}
+	}
+	return ensureBlock(manifest.Properties, strings.Join(keys, "."), func(path string) error {
+		return missing("properties." + path)
+	})
 }
 
-func (t *Transformer) addAcceptanceJobProperties(manifest, acceptanceJobConfig map[interface{}]interface{}) (err error) {
-	defer dynRecover("add acceptance with cf job properties", &err)
-
-	props := manifest["properties"].(map[interface{}]interface{})
-	props["acceptance-with-cf"] = acceptanceJobConfig
+// ensureBlock returns the map at path in props, creating missing maps on
+// the way after calling missing, if given, with each of their paths.
+func ensureBlock(props map[interface{}]interface{}, path string, missing func(path string) error) (map[interface{}]interface{}, error) {
+	if path == "" {
+		return props, nil
+	}
 
-	return nil
+	keys := strings.Split(path, ".")
+	var val interface{}
+	for i := range keys {
+		prefix := strings.Join(keys[:i+1], ".")
+		var err error
+		val, err = manifestpath.Get(props, prefix)
+		if err != nil && !manifestpath.IsNotFound(err) {
+			return nil, err
+		}
+		if val == nil {
+			if missing != nil {
+				err = missing(prefix)
+				if err != nil {
+					return nil, err
+				}
+			}
+			val = map[interface{}]interface{}{}
+			err = manifestpath.Set(props, prefix, val)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := val.(map[interface{}]interface{}); !ok {
+			return nil, fmt.Errorf("expected properties.%s to be a map, got %T", prefix, val)
+		}
+	}
+	return val.(map[interface{}]interface{}), nil
 }
 
-func getManifestElement(manifest map[interface{}]interface{}, keys ...string) (ret interface{}, err error) {
-	defer dynRecover("get manifest properties", &err)
-
-	var val interface{} = manifest
-	for len(keys) > 0 {
-		val = val.(map[interface{}]interface{})[keys[0]]
-		keys = keys[1:]
+// lookupProperty returns the value at path in a properties map, or nil if
+// there is none.
+func lookupProperty(props map[interface{}]interface{}, path string) (interface{}, error) {
+	val, err := manifestpath.Get(props, path)
+	if manifestpath.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("properties: %s", err)
 	}
-
 	return val, nil
 }
 
-func getNatsProperties(manifest map[interface{}]interface{}) (interface{}, error) {
-	return getManifestElement(manifest, "properties", "diego", "route_emitter", "nats")
+func getNatsProperties(manifest *Manifest) (interface{}, error) {
+	nats, err := lookupProperty(manifest.Properties, "diego.route_emitter.nats")
+	if err != nil {
+		return nil, err
+	}
+	if nats == nil {
+		return nil, fmt.Errorf("manifest has no properties.diego.route_emitter.nats")
+	}
+	return nats, nil
 }
@@ -1,6 +1,7 @@
 package ducatify_test
 
 import (
+	"github.com/cloudfoundry-incubator/candiedyaml"
 	"github.com/cloudfoundry-incubator/ducatify"
 
 	. "github.com/onsi/ginkgo"
@@ -67,6 +68,37 @@ var _ = Describe("Transform", func() {
 		}
 	})
 
+	transform := func() error {
+		transformed, err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+		if err == nil {
+			manifest = transformed
+		}
+		return err
+	}
+
+	It("returns a new manifest and leaves its input untouched", func() {
+		original := copyValue(manifest)
+
+		transformed, err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest).To(Equal(original))
+		Expect(transformed["releases"]).To(ContainElement(
+			map[interface{}]interface{}{"name": "ducati", "version": "latest"},
+		))
+	})
+
+	It("leaves its input untouched when a step fails midway", func() {
+		manifest["jobs"] = []interface{}{
+			map[interface{}]interface{}{"name": "cell_z1", "templates": []interface{}{}},
+		}
+		original := copyValue(manifest)
+
+		transformed, err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+		Expect(err).To(MatchError(ContainSubstring("database_z1 job not found")))
+		Expect(transformed).To(BeNil())
+		Expect(manifest).To(Equal(original))
+	})
+
 	// trying to use new naming convention:
 	// https://github.com/cloudfoundry/bosh-notes/blob/master/deployment-naming.md
 	Describe("modifying cell instance groups", func() {
@@ -108,7 +140,7 @@ var _ = Describe("Transform", func() {
 		})
 
 		It("colocates connet job template onto every cc_bridge instance group", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 			jobs := manifest["jobs"].([]interface{})
 			Expect(jobs[5]).To(Equal(map[interface{}]interface{}{
@@ -137,8 +169,78 @@ var _ = Describe("Transform", func() {
 			}))
 		})
 
+		Context("when a cc_bridge already has route_registrar configured", func() {
+			BeforeEach(func() {
+				ccBridge := manifest["jobs"].([]interface{})[4].(map[interface{}]interface{})
+				ccBridge["properties"] = map[interface{}]interface{}{
+					"route_registrar": map[interface{}]interface{}{
+						"routes": []interface{}{
+							map[interface{}]interface{}{"name": "some-route", "port": 1234},
+							map[interface{}]interface{}{"name": "connet", "port": 1},
+						},
+					},
+				}
+				ccBridge["templates"] = append(ccBridge["templates"].([]interface{}),
+					map[interface{}]interface{}{"name": "route_registrar", "release": "cf"},
+				)
+			})
+
+			It("merges the connet route into the existing routes", func() {
+				err := transform()
+				Expect(err).NotTo(HaveOccurred())
+
+				jobs := manifest["jobs"].([]interface{})
+				properties := jobs[5].(map[interface{}]interface{})["properties"].(map[interface{}]interface{})
+				Expect(properties["route_registrar"]).To(Equal(map[interface{}]interface{}{
+					"routes": []interface{}{
+						map[interface{}]interface{}{"name": "some-route", "port": 1234},
+						map[interface{}]interface{}{
+							"name":                  "connet",
+							"registration_interval": "20s",
+							"port":                  4002,
+							"uris":                  []string{"connet.some.system.domain"},
+						},
+					},
+				}))
+			})
+
+			It("does not add a second route_registrar template", func() {
+				err := transform()
+				Expect(err).NotTo(HaveOccurred())
+
+				jobs := manifest["jobs"].([]interface{})
+				Expect(jobs[5].(map[interface{}]interface{})["templates"]).To(Equal([]interface{}{
+					map[interface{}]interface{}{"name": "some-template", "release": "some-release"},
+					map[interface{}]interface{}{"name": "route_registrar", "release": "cf"},
+					map[interface{}]interface{}{"name": "connet", "release": "ducati"},
+				}))
+			})
+		})
+
+		It("uses the configured connet port, registration interval and hostname prefix", func() {
+			transformer.ConnetPort = 5555
+			transformer.ConnetRegistrationInterval = "1m"
+			transformer.ConnetHostnamePrefix = "policy"
+
+			err := transform()
+			Expect(err).NotTo(HaveOccurred())
+
+			jobs := manifest["jobs"].([]interface{})
+			properties := jobs[5].(map[interface{}]interface{})["properties"].(map[interface{}]interface{})
+			Expect(properties["route_registrar"]).To(Equal(map[interface{}]interface{}{
+				"routes": []interface{}{
+					map[interface{}]interface{}{
+						"name":                  "connet",
+						"registration_interval": "1m",
+						"port":                  5555,
+						"uris":                  []string{"policy.some.system.domain"},
+					},
+				},
+			}))
+		})
+
 		It("colocates ducati template onto every cell instance group", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 			jobs := manifest["jobs"].([]interface{})
 			Expect(jobs[2]).To(Equal(map[interface{}]interface{}{
@@ -178,7 +280,7 @@ var _ = Describe("Transform", func() {
 		})
 
 		It("colocates ducati template onto the 'colocated' job", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 			jobs := manifest["jobs"].([]interface{})
 			Expect(jobs[2]).To(Equal(map[interface{}]interface{}{
@@ -196,7 +298,7 @@ var _ = Describe("Transform", func() {
 
 	Describe("adding new jobs", func() {
 		It("adds the ducati_db job", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 			jobs := manifest["jobs"].([]interface{})
 			Expect(jobs).To(ContainElement(map[interface{}]interface{}{
@@ -231,8 +333,68 @@ var _ = Describe("Transform", func() {
 			}))
 		})
 
+		Context("when the database is found through BOSH DNS", func() {
+			BeforeEach(func() {
+				transformer.ServiceDiscovery = ducatify.ServiceDiscoveryBOSHDNS
+				transformer.DBAlias = "some-db.some-domain"
+			})
+
+			It("provides a link with a DNS alias instead of colocating consul_agent", func() {
+				err := transform()
+				Expect(err).NotTo(HaveOccurred())
+				jobs := manifest["jobs"].([]interface{})
+				Expect(jobs).To(ContainElement(map[interface{}]interface{}{
+					"name":            "ducati_db",
+					"instances":       1,
+					"persistent_disk": 256,
+					"resource_pool":   "database_z1",
+					"networks": []interface{}{
+						map[interface{}]interface{}{
+							"name": "diego1",
+						},
+					},
+					"templates": []interface{}{
+						map[interface{}]interface{}{
+							"name":    "postgres",
+							"release": "ducati",
+							"provides": map[interface{}]interface{}{
+								"database": map[interface{}]interface{}{
+									"as": "ducati_db",
+									"aliases": []interface{}{
+										map[interface{}]interface{}{"domain": "some-db.some-domain"},
+									},
+								},
+							},
+						},
+					},
+				}))
+			})
+
+			It("points the daemons at the alias", func() {
+				err := transform()
+				Expect(err).NotTo(HaveOccurred())
+				props := manifest["properties"].(map[interface{}]interface{})
+				for _, tree := range []string{"ducati", "connet"} {
+					daemon := props[tree].(map[interface{}]interface{})["daemon"].(map[interface{}]interface{})
+					Expect(daemon["database"]).To(HaveKeyWithValue("host", "some-db.some-domain"))
+				}
+			})
+
+			It("requires an alias", func() {
+				transformer.DBAlias = ""
+				err := transform()
+				Expect(err).To(MatchError("checking service discovery: bosh-dns needs a db_alias for the database"))
+			})
+		})
+
+		It("rejects unknown service discovery", func() {
+			transformer.ServiceDiscovery = "zookeeper"
+			err := transform()
+			Expect(err).To(MatchError(ContainSubstring(`unsupported service discovery "zookeeper"`)))
+		})
+
 		It("adds the ducati acceptance test job", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 			jobs := manifest["jobs"].([]interface{})
 			Expect(jobs).To(ContainElement(map[interface{}]interface{}{
@@ -261,7 +423,7 @@ var _ = Describe("Transform", func() {
 		})
 
 		It("adds the ducati release", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifest).To(HaveKey("releases"))
@@ -280,7 +442,7 @@ var _ = Describe("Transform", func() {
 
 	Describe("adding garden properties", func() {
 		It("sets the network plugin properties", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifest["properties"]).To(HaveKeyWithValue("garden",
@@ -298,11 +460,72 @@ var _ = Describe("Transform", func() {
 					},
 				}))
 		})
+
+		Context("when the garden properties already have values", func() {
+			var gardenProps map[interface{}]interface{}
+
+			BeforeEach(func() {
+				gardenProps = manifest["properties"].(map[interface{}]interface{})["garden"].(map[interface{}]interface{})
+				gardenProps["shared_mounts"] = []interface{}{"/some/mount", "/var/vcap/data/ducati/container-netns"}
+				gardenProps["network_plugin_extra_args"] = []interface{}{"--some-arg"}
+				gardenProps["dns_servers"] = []interface{}{"8.8.8.8"}
+			})
+
+			It("appends to the existing lists without duplicates", func() {
+				err := transform()
+				Expect(err).NotTo(HaveOccurred())
+				gardenProps = manifest["properties"].(map[interface{}]interface{})["garden"].(map[interface{}]interface{})
+
+				Expect(gardenProps["shared_mounts"]).To(Equal([]string{
+					"/some/mount",
+					"/var/vcap/data/ducati/container-netns",
+				}))
+				Expect(gardenProps["network_plugin_extra_args"]).To(Equal([]string{
+					"--some-arg",
+					"--configFile=/var/vcap/jobs/ducati/config/adapter.json",
+				}))
+				Expect(gardenProps["dns_servers"]).To(Equal([]string{"8.8.8.8", "192.168.255.254"}))
+			})
+
+			It("returns an error when an existing list contains something other than strings", func() {
+				gardenProps["dns_servers"] = []interface{}{42}
+
+				err := transform()
+				Expect(err).To(MatchError(ContainSubstring("merging dns_servers")))
+			})
+		})
+
+		Context("when a different network plugin is already configured", func() {
+			var warnings []string
+
+			BeforeEach(func() {
+				warnings = nil
+				transformer.Warn = func(msg string) { warnings = append(warnings, msg) }
+				gardenProps := manifest["properties"].(map[interface{}]interface{})["garden"].(map[interface{}]interface{})
+				gardenProps["network_plugin"] = "/some/other/plugin"
+			})
+
+			It("replaces it and warns by default", func() {
+				err := transform()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(manifest["properties"].(map[interface{}]interface{})["garden"]).To(HaveKeyWithValue(
+					"network_plugin", "/var/vcap/packages/ducati/bin/guardian-cni-adapter"))
+				Expect(warnings).To(ConsistOf(ContainSubstring(`replacing garden network_plugin "/some/other/plugin"`)))
+			})
+
+			It("fails when the conflict policy is fail", func() {
+				transformer.GardenNetworkPluginConflict = ducatify.ConflictFail
+
+				err := transform()
+				Expect(err).To(MatchError(ContainSubstring(`network_plugin already set to "/some/other/plugin"`)))
+			})
+		})
 	})
 
 	Describe("adding nsync properties", func() {
 		It("sets the nsync network id", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifest["properties"]).To(HaveKeyWithValue("diego",
@@ -321,14 +544,62 @@ var _ = Describe("Transform", func() {
 		})
 	})
 
+	Describe("when property blocks are left to job defaults", func() {
+		var warnings []string
+
+		BeforeEach(func() {
+			warnings = nil
+			transformer.Warn = func(msg string) { warnings = append(warnings, msg) }
+
+			props := manifest["properties"].(map[interface{}]interface{})
+			delete(props, "garden")
+			delete(props["diego"].(map[interface{}]interface{}), "nsync")
+		})
+
+		It("creates the missing blocks and warns about them", func() {
+			err := transform()
+			Expect(err).NotTo(HaveOccurred())
+
+			props := manifest["properties"].(map[interface{}]interface{})
+			Expect(props["garden"]).To(HaveKeyWithValue("network_plugin", "/var/vcap/packages/ducati/bin/guardian-cni-adapter"))
+			Expect(props["diego"]).To(HaveKeyWithValue("nsync", map[interface{}]interface{}{
+				"network_id": "ducati-overlay",
+			}))
+			Expect(warnings).To(ConsistOf(
+				"manifest has no properties.garden, creating it",
+				"manifest has no properties.diego.nsync, creating it",
+			))
+		})
+
+		Context("when the transformer is strict", func() {
+			BeforeEach(func() {
+				transformer.Strict = true
+			})
+
+			It("returns an error naming the missing block", func() {
+				err := transform()
+				Expect(err).To(MatchError("adding garden properties: manifest has no properties.garden"))
+			})
+		})
+
+		It("returns an error when a block is not a map", func() {
+			manifest["properties"].(map[interface{}]interface{})["garden"] = "not-a-map"
+
+			err := transform()
+			Expect(err).To(MatchError("adding garden properties: expected properties.garden to be a map, got string"))
+		})
+	})
+
 	Describe("adding ducati properties", func() {
 		It("adds properties for ducati", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifest["properties"]).To(HaveKeyWithValue("ducati",
 				map[interface{}]interface{}{
 					"daemon": map[interface{}]interface{}{
+						"overlay_network":      "10.255.0.0/16",
+						"subnet_prefix_length": 24,
 						"database": map[interface{}]interface{}{
 							"username": "ducati_daemon",
 							"password": "some-password",
@@ -361,7 +632,7 @@ var _ = Describe("Transform", func() {
 
 	Describe("adding connet properties", func() {
 		It("adds properties for connet", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifest["properties"]).To(HaveKeyWithValue("connet",
@@ -381,12 +652,134 @@ var _ = Describe("Transform", func() {
 		})
 	})
 
+	Describe("handling existing property trees", func() {
+		var props map[interface{}]interface{}
+
+		BeforeEach(func() {
+			props = manifest["properties"].(map[interface{}]interface{})
+			props["connet"] = map[interface{}]interface{}{
+				"some-operator-setting": "some-value",
+				"daemon": map[interface{}]interface{}{
+					"database": map[interface{}]interface{}{
+						"password": "operator-password",
+						"port":     5432,
+					},
+				},
+			}
+		})
+
+		It("overwrites the existing tree by default", func() {
+			err := transform()
+			Expect(err).NotTo(HaveOccurred())
+			props = manifest["properties"].(map[interface{}]interface{})
+
+			Expect(props["connet"]).NotTo(HaveKey("some-operator-setting"))
+		})
+
+		Context("when the policy is merge", func() {
+			BeforeEach(func() {
+				transformer.PropertyConflicts = map[string]ducatify.ConflictPolicy{
+					"connet": ducatify.ConflictMerge,
+				}
+			})
+
+			It("keeps unrelated existing values and lets ducatify win on conflicts", func() {
+				err := transform()
+				Expect(err).NotTo(HaveOccurred())
+				props = manifest["properties"].(map[interface{}]interface{})
+
+				Expect(props["connet"]).To(HaveKeyWithValue("some-operator-setting", "some-value"))
+				database := props["connet"].(map[interface{}]interface{})["daemon"].(map[interface{}]interface{})["database"]
+				Expect(database).To(HaveKeyWithValue("password", "some-password"))
+				Expect(database).To(HaveKeyWithValue("host", "ducati-db.service.cf.internal"))
+			})
+		})
+
+		Context("when the policy is fail", func() {
+			BeforeEach(func() {
+				transformer.PropertyConflicts = map[string]ducatify.ConflictPolicy{
+					"connet": ducatify.ConflictFail,
+				}
+			})
+
+			It("names each conflicting path along with both values", func() {
+				err := transform()
+				Expect(err).To(MatchError(ContainSubstring(
+					`properties.connet.daemon.database.password: manifest has "operator-password", ducatify wants "some-password"`)))
+				Expect(err.Error()).NotTo(ContainSubstring("port"))
+			})
+
+			It("does not report integers that differ only in their type", func() {
+				props["connet"].(map[interface{}]interface{})["daemon"].(map[interface{}]interface{})["database"].(map[interface{}]interface{})["port"] = int64(5432)
+
+				err := transform()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).NotTo(ContainSubstring("port"))
+			})
+
+			It("accepts its own output parsed back from yaml", func() {
+				transformer.PropertyConflicts = nil
+				Expect(transform()).To(Succeed())
+
+				bytes, err := candiedyaml.Marshal(manifest)
+				Expect(err).NotTo(HaveOccurred())
+				manifest = map[interface{}]interface{}{}
+				Expect(candiedyaml.Unmarshal(bytes, &manifest)).To(Succeed())
+
+				transformer.PropertyConflicts = map[string]ducatify.ConflictPolicy{}
+				for _, tree := range ducatify.PropertyTrees {
+					transformer.PropertyConflicts[tree] = ducatify.ConflictFail
+				}
+				Expect(transform()).To(Succeed())
+			})
+
+			It("merges when the existing values agree", func() {
+				props["connet"].(map[interface{}]interface{})["daemon"].(map[interface{}]interface{})["database"].(map[interface{}]interface{})["password"] = "some-password"
+
+				err := transform()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(props["connet"]).To(HaveKeyWithValue("some-operator-setting", "some-value"))
+			})
+		})
+
+		It("rejects unknown policies", func() {
+			transformer.PropertyConflicts = map[string]ducatify.ConflictPolicy{"connet": "bogus"}
+
+			err := transform()
+			Expect(err).To(MatchError(ContainSubstring(`invalid property_conflicts policy "bogus" for connet`)))
+		})
+	})
+
 	Describe("adding acceptance-with-cf properties", func() {
 		It("adds properties for acceptance with ducati", func() {
-			err := transformer.Transform(manifest, acceptanceJobConfig, systemDomain)
+			err := transform()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifest["properties"]).To(HaveKeyWithValue("acceptance-with-cf", acceptanceJobConfig))
 		})
 	})
 })
+
+// copyValue deep copies a yaml document, so that it can be compared with
+// the document later.
+func copyValue(val interface{}) map[interface{}]interface{} {
+	var copyAny func(val interface{}) interface{}
+	copyAny = func(val interface{}) interface{} {
+		switch v := val.(type) {
+		case map[interface{}]interface{}:
+			copied := map[interface{}]interface{}{}
+			for key, el := range v {
+				copied[key] = copyAny(el)
+			}
+			return copied
+		case []interface{}:
+			copied := []interface{}{}
+			for _, el := range v {
+				copied = append(copied, copyAny(el))
+			}
+			return copied
+		}
+		return val
+	}
+	return copyAny(val).(map[interface{}]interface{})
+}
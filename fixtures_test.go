@@ -0,0 +1,67 @@
+package ducatify_test
+
+import "github.com/cloudfoundry-incubator/ducatify"
+
+// cellJob builds a cell job for a test manifest. It runs no templates, has
+// a rep zone unless zone is empty, and is placed on the given networks.
+func cellJob(name, zone string, networks ...string) map[interface{}]interface{} {
+	job := map[interface{}]interface{}{
+		"name":      name,
+		"instances": 1,
+		"templates": []interface{}{},
+	}
+	if zone != "" {
+		job["properties"] = map[interface{}]interface{}{
+			"diego": map[interface{}]interface{}{
+				"rep": map[interface{}]interface{}{"zone": zone},
+			},
+		}
+	}
+	if len(networks) > 0 {
+		jobNetworks := []interface{}{}
+		for _, network := range networks {
+			jobNetworks = append(jobNetworks, map[interface{}]interface{}{"name": network})
+		}
+		job["networks"] = jobNetworks
+	}
+	return job
+}
+
+// inAZs places a test cell job in the given AZs.
+func inAZs(job map[interface{}]interface{}, azs ...interface{}) map[interface{}]interface{} {
+	job["azs"] = azs
+	return job
+}
+
+// recordWarnings makes transformer append its warnings to warnings.
+func recordWarnings(transformer *ducatify.Transformer, warnings *[]string) {
+	*warnings = nil
+	transformer.Warn = func(msg string) { *warnings = append(*warnings, msg) }
+}
+
+// normalizeIntegers converts every integer in a yaml document to int64, so
+// that documents compare equal whichever integer type the yaml package
+// decoded to.
+func normalizeIntegers(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		normalized := map[interface{}]interface{}{}
+		for key, el := range v {
+			normalized[key] = normalizeIntegers(el)
+		}
+		return normalized
+	case []interface{}:
+		normalized := []interface{}{}
+		for _, el := range v {
+			normalized = append(normalized, normalizeIntegers(el))
+		}
+		return normalized
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case uint64:
+		return int64(v)
+	}
+	return val
+}
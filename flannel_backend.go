@@ -0,0 +1,69 @@
+package ducatify
+
+// flannelBackend adds a flannel-style vxlan overlay. Cells coordinate their
+// subnets through etcd, so there is no database job and nothing changes on
+// the cc_bridges.
+type flannelBackend struct{}
+
+func (flannelBackend) Defaults(t *Transformer) {
+	t.GardenSharedMounts = []string{"/var/vcap/data/flannel/container-netns"}
+	t.GardenNetworkPlugin = "/var/vcap/packages/flannel-cni/bin/guardian-cni-adapter"
+	t.GardenNetworkPluginExtraArgs = []string{"--configFile=/var/vcap/jobs/flannel/config/adapter.json"}
+	t.NsyncNetworkID = "flannel-overlay"
+}
+
+func (flannelBackend) Releases(t *Transformer) []*Release {
+	return []*Release{{Name: "flannel", Version: t.ReleaseVersion}}
+}
+
+func (flannelBackend) AddJobs(t *Transformer, manifest *Manifest) error {
+	return nil
+}
+
+func (flannelBackend) ModifyCCBridge(t *Transformer, manifest *Manifest, systemDomain string) error {
+	return nil
+}
+
+func (flannelBackend) CellTemplates(t *Transformer, job string) []*Template {
+	return []*Template{{Name: "flannel", Release: "flannel"}}
+}
+
+func (flannelBackend) PropertyTrees(t *Transformer) []PropertyTree {
+	endpoints := []interface{}{}
+	for _, endpoint := range t.FlannelEtcdEndpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return []PropertyTree{{
+		Name: "flannel",
+		Value: map[interface{}]interface{}{
+			"network":    t.OverlayNetwork,
+			"subnet_len": t.OverlaySubnetPrefixLength,
+			"backend": map[interface{}]interface{}{
+				"type": "vxlan",
+			},
+			"etcd": map[interface{}]interface{}{
+				"endpoints": endpoints,
+			},
+		},
+	}}
+}
+
+func (flannelBackend) AcceptanceErrand(t *Transformer) (*Job, string) {
+	return nil, ""
+}
+
+func (flannelBackend) Verify(t *Transformer, manifest *Manifest) ([]VerifyResult, error) {
+	flannel, err := lookupProperty(manifest.Properties, "flannel")
+	if err != nil {
+		return nil, err
+	}
+	if flannel == nil {
+		return []VerifyResult{failed("flannel properties", "missing")}, nil
+	}
+	return []VerifyResult{passed("flannel properties")}, nil
+}
+
+func (flannelBackend) SetupStage() (string, string) {
+	return "flannel-release", "add the flannel release and its properties; no cells change"
+}
@@ -0,0 +1,144 @@
+package ducatify
+
+import "fmt"
+
+// GardenOverride holds the garden network settings of a single cell job
+// that differ from the Transformer's defaults. Empty fields keep the
+// defaults.
+type GardenOverride struct {
+	NetworkPlugin          string   `yaml:"network_plugin"`
+	NetworkPluginExtraArgs []string `yaml:"network_plugin_extra_args"`
+	DNSServers             []string `yaml:"dns_servers"`
+}
+
+type gardenSettings struct {
+	networkPlugin string
+	extraArgs     []string
+	sharedMounts  []string
+	dnsServers    []string
+}
+
+// apply returns settings with the fields that the override sets replaced.
+func (o GardenOverride) apply(settings gardenSettings) gardenSettings {
+	if o.NetworkPlugin != "" {
+		settings.networkPlugin = o.NetworkPlugin
+	}
+	if o.NetworkPluginExtraArgs != nil {
+		settings.extraArgs = o.NetworkPluginExtraArgs
+	}
+	if o.DNSServers != nil {
+		settings.dnsServers = o.DNSServers
+	}
+	return settings
+}
+
+func (t *Transformer) addGardenProperties(manifest *Manifest) error {
+	dnsServers, err := t.gardenDNSServers(manifest)
+	if err != nil {
+		return err
+	}
+	defaults := gardenSettings{
+		networkPlugin: t.GardenNetworkPlugin,
+		extraArgs:     t.GardenNetworkPluginExtraArgs,
+		sharedMounts:  t.GardenSharedMounts,
+		dnsServers:    dnsServers,
+	}
+
+	cells, partial, err := t.ducatiCells(manifest)
+	if err != nil {
+		return err
+	}
+
+	overridden := map[string]bool{}
+	for _, job := range cells {
+		if _, ok := t.GardenOverrides[job.Name]; ok {
+			overridden[job.Name] = true
+		}
+	}
+	for name := range t.GardenOverrides {
+		if !overridden[name] {
+			t.warnf("garden override for %s does not match any cell job with %s", name, t.Backend)
+		}
+	}
+
+	// the global block also configures the cells without ducati, which
+	// don't have the network plugin, so then scope the settings to each job
+	scoped := partial || t.scopeGardenToCells
+	if !scoped {
+		gardenProps, err := t.ensurePropertyBlock(manifest, "garden")
+		if err != nil {
+			return err
+		}
+		err = t.applyGardenProperties(gardenProps, defaults)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, job := range cells {
+		override, hasOverride := t.GardenOverrides[job.Name]
+		if !scoped && !hasOverride {
+			continue
+		}
+
+		var settings gardenSettings
+		if scoped {
+			settings = defaults
+		}
+		settings = override.apply(settings)
+
+		gardenProps, err := jobGardenProperties(job)
+		if err == nil {
+			err = t.applyGardenProperties(gardenProps, settings)
+		}
+		if err != nil {
+			return fmt.Errorf("job %s: %s", job.Name, err)
+		}
+	}
+	return nil
+}
+
+// jobGardenProperties returns the garden block of a job's own properties,
+// creating it if needed.
+func jobGardenProperties(job *Job) (map[interface{}]interface{}, error) {
+	if job.Properties == nil {
+		job.Properties = map[interface{}]interface{}{}
+	}
+	return ensureBlock(job.Properties, "garden", nil)
+}
+
+// applyGardenProperties writes settings into a garden properties block,
+// skipping the network plugin when it is empty and any list that is empty
+// and not yet present.
+func (t *Transformer) applyGardenProperties(gardenProps map[interface{}]interface{}, settings gardenSettings) error {
+	if settings.networkPlugin != "" {
+		existingPlugin, _ := gardenProps["network_plugin"].(string)
+		if existingPlugin != "" && existingPlugin != settings.networkPlugin {
+			switch t.GardenNetworkPluginConflict {
+			case ConflictWarn:
+				t.warnf("replacing garden network_plugin %q with %q", existingPlugin, settings.networkPlugin)
+			case ConflictFail:
+				return fmt.Errorf("garden network_plugin already set to %q, refusing to replace it with %q", existingPlugin, settings.networkPlugin)
+			default:
+				return fmt.Errorf("unsupported network_plugin conflict policy %q", t.GardenNetworkPluginConflict)
+			}
+		}
+		gardenProps["network_plugin"] = settings.networkPlugin
+	}
+
+	for key, additions := range map[string][]string{
+		"network_plugin_extra_args": settings.extraArgs,
+		"shared_mounts":             settings.sharedMounts,
+		"dns_servers":               settings.dnsServers,
+	} {
+		if _, exists := gardenProps[key]; !exists && len(additions) == 0 {
+			continue
+		}
+		merged, err := mergeStringList(gardenProps[key], additions)
+		if err != nil {
+			return fmt.Errorf("merging %s: %s", key, err)
+		}
+		gardenProps[key] = merged
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Per-job garden overrides", func() {
+	var (
+		manifest    map[interface{}]interface{}
+		transformer *ducatify.Transformer
+		warnings    []string
+	)
+
+	jobGarden := func(name string) interface{} {
+		for _, job := range manifest["jobs"].([]interface{}) {
+			jobMap := job.(map[interface{}]interface{})
+			if jobMap["name"] != name {
+				continue
+			}
+			props, ok := jobMap["properties"].(map[interface{}]interface{})
+			if !ok {
+				return nil
+			}
+			return props["garden"]
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		recordWarnings(transformer, &warnings)
+		transformer.GardenOverrides = map[string]ducatify.GardenOverride{
+			"cell_z2": {
+				NetworkPluginExtraArgs: []string{"--configFile=/some/other/adapter.json"},
+				DNSServers:             []string{"10.0.1.2"},
+			},
+		}
+
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "templates": []interface{}{}},
+				cellJob("cell_z1", "", "diego1"),
+				cellJob("cell_z2", "", "diego2"),
+			},
+			"networks": []interface{}{
+				map[interface{}]interface{}{
+					"name":    "diego1",
+					"subnets": []interface{}{map[interface{}]interface{}{"dns": []interface{}{"10.0.0.2"}}},
+				},
+				map[interface{}]interface{}{
+					"name":    "diego2",
+					"subnets": []interface{}{map[interface{}]interface{}{"dns": []interface{}{"10.0.1.2"}}},
+				},
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	transform := func() error {
+		transformed, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		if err == nil {
+			manifest = transformed
+		}
+		return err
+	}
+
+	It("keeps the defaults in the global garden block", func() {
+		Expect(transform()).To(Succeed())
+
+		Expect(manifest["properties"]).To(HaveKeyWithValue("garden", map[interface{}]interface{}{
+			"network_plugin":            "/var/vcap/packages/ducati/bin/guardian-cni-adapter",
+			"network_plugin_extra_args": []string{"--configFile=/var/vcap/jobs/ducati/config/adapter.json"},
+			"shared_mounts":             []string{"/var/vcap/data/ducati/container-netns"},
+			"dns_servers":               []string{"10.0.0.2"},
+		}))
+	})
+
+	It("writes only the overridden settings into the job's own garden block", func() {
+		Expect(transform()).To(Succeed())
+
+		Expect(jobGarden("cell_z1")).To(BeNil())
+		Expect(jobGarden("cell_z2")).To(Equal(map[interface{}]interface{}{
+			"network_plugin_extra_args": []string{"--configFile=/some/other/adapter.json"},
+			"dns_servers":               []string{"10.0.1.2"},
+		}))
+	})
+
+	It("leaves overridden cells out of the zone DNS comparison", func() {
+		Expect(transform()).To(Succeed())
+		Expect(warnings).To(BeEmpty())
+	})
+
+	It("warns about overrides for jobs that are not ducati cells", func() {
+		transformer.GardenOverrides["cell_z9"] = ducatify.GardenOverride{NetworkPlugin: "/some/plugin"}
+
+		Expect(transform()).To(Succeed())
+		Expect(warnings).To(ConsistOf("garden override for cell_z9 does not match any cell job with ducati"))
+	})
+
+	Context("when the garden settings are scoped to each cell", func() {
+		BeforeEach(func() {
+			transformer.Cells.ExcludeJobs = []string{"cell_z3"}
+			manifest["jobs"] = append(manifest["jobs"].([]interface{}), cellJob("cell_z3", "", "diego1"))
+		})
+
+		It("combines the defaults with the job's overrides", func() {
+			Expect(transform()).To(Succeed())
+
+			Expect(jobGarden("cell_z2")).To(Equal(map[interface{}]interface{}{
+				"network_plugin":            "/var/vcap/packages/ducati/bin/guardian-cni-adapter",
+				"network_plugin_extra_args": []string{"--configFile=/some/other/adapter.json"},
+				"shared_mounts":             []string{"/var/vcap/data/ducati/container-netns"},
+				"dns_servers":               []string{"10.0.1.2"},
+			}))
+			Expect(jobGarden("cell_z1")).To(HaveKeyWithValue("dns_servers", []string{"10.0.0.2"}))
+		})
+	})
+})
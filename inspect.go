@@ -0,0 +1,301 @@
+package ducatify
+
+import "fmt"
+
+// Inspection summarizes the container networking configuration of a
+// manifest.
+type Inspection struct {
+	SchemaVersion int `json:"schema_version"`
+
+	// SchemaVersionProblem explains a schema version marker that is
+	// malformed, leaving SchemaVersion 0, or newer than this ducatify
+	// knows.
+	SchemaVersionProblem string `json:"schema_version_problem,omitempty"`
+
+	Releases       []ReleaseInfo `json:"releases"`
+	Jobs           []JobInfo     `json:"jobs"`
+	Database       *DatabaseInfo `json:"database"`
+	Garden         []GardenInfo  `json:"garden"`
+	NsyncNetworkID string        `json:"nsync_network_id"`
+	Routes         []RouteInfo   `json:"routes"`
+}
+
+// ReleaseInfo is a container networking release in the manifest.
+type ReleaseInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// JobInfo is a job that runs templates of a container networking release.
+type JobInfo struct {
+	Name      string   `json:"name"`
+	Instances int      `json:"instances"`
+	Templates []string `json:"templates"`
+}
+
+// DatabaseInfo describes where the daemons find their database. The
+// password is never included.
+type DatabaseInfo struct {
+	Host        string `json:"host"`
+	Port        string `json:"port"`
+	Name        string `json:"name"`
+	Username    string `json:"username"`
+	SSLMode     string `json:"ssl_mode"`
+	PasswordSet bool   `json:"password_set"`
+
+	// Location says which job runs the database and how the daemons find
+	// it, or that it is external.
+	Location string `json:"location"`
+
+	// CredentialsSource says where the database user comes from.
+	CredentialsSource string `json:"credentials_source"`
+}
+
+// GardenInfo is a garden properties block, either the global one or that
+// of a single job.
+type GardenInfo struct {
+	Scope                  string   `json:"scope"`
+	NetworkPlugin          string   `json:"network_plugin"`
+	NetworkPluginExtraArgs []string `json:"network_plugin_extra_args"`
+	SharedMounts           []string `json:"shared_mounts"`
+	DNSServers             []string `json:"dns_servers"`
+}
+
+// RouteInfo is a route_registrar route, with the job that registers it or
+// "global" for the global properties.
+type RouteInfo struct {
+	Scope string   `json:"scope"`
+	Name  string   `json:"name"`
+	Port  string   `json:"port"`
+	URIs  []string `json:"uris"`
+}
+
+// Inspect summarizes the container networking configuration of manifest
+// for every registered backend. It does not change the manifest.
+func (t *Transformer) Inspect(manifest map[interface{}]interface{}) (*Inspection, error) {
+	m, err := DecodeManifest(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("decoding manifest: %s", err)
+	}
+	return t.InspectManifest(m)
+}
+
+// InspectManifest is Inspect for a decoded Manifest. A bad schema version
+// marker is reported in the summary rather than as an error, since the
+// rest of the summary is still useful.
+func (t *Transformer) InspectManifest(manifest *Manifest) (*Inspection, error) {
+	inspection := &Inspection{
+		Releases: []ReleaseInfo{},
+		Jobs:     []JobInfo{},
+		Garden:   []GardenInfo{},
+		Routes:   []RouteInfo{},
+	}
+
+	version, err := ManifestSchemaVersion(manifest)
+	switch {
+	case err != nil:
+		inspection.SchemaVersionProblem = err.Error()
+	case version > SchemaVersion:
+		inspection.SchemaVersion = version
+		inspection.SchemaVersionProblem = fmt.Sprintf("newer than %d, which this ducatify supports", SchemaVersion)
+	default:
+		inspection.SchemaVersion = version
+	}
+
+	networkingReleases := t.networkingReleases()
+	for _, release := range manifest.Releases {
+		if networkingReleases[release.Name] {
+			inspection.Releases = append(inspection.Releases, ReleaseInfo{
+				Name:    release.Name,
+				Version: fmt.Sprint(release.Version),
+			})
+		}
+	}
+
+	for _, job := range manifest.Jobs {
+		templates := []string{}
+		for _, template := range job.Templates {
+			if networkingReleases[template.Release] {
+				templates = append(templates, template.Name)
+			}
+		}
+		if len(templates) > 0 {
+			inspection.Jobs = append(inspection.Jobs, JobInfo{
+				Name:      job.Name,
+				Instances: job.Instances,
+				Templates: templates,
+			})
+		}
+	}
+
+	inspection.Database, err = inspectDatabase(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []propertyScope{{"global", manifest.Properties}}
+	for _, job := range manifest.Jobs {
+		scopes = append(scopes, propertyScope{job.Name, job.Properties})
+	}
+	for _, scope := range scopes {
+		garden, err := inspectGarden(scope.name, scope.props)
+		if err != nil {
+			return nil, err
+		}
+		if garden != nil {
+			inspection.Garden = append(inspection.Garden, *garden)
+		}
+
+		routes, err := inspectRoutes(scope.name, scope.props)
+		if err != nil {
+			return nil, err
+		}
+		inspection.Routes = append(inspection.Routes, routes...)
+	}
+
+	networkID, err := lookupProperty(manifest.Properties, "diego.nsync.network_id")
+	if err != nil {
+		return nil, err
+	}
+	if networkID != nil {
+		inspection.NsyncNetworkID = fmt.Sprint(networkID)
+	}
+	return inspection, nil
+}
+
+// propertyScope is the global properties or those of a job.
+type propertyScope struct {
+	name  string
+	props map[interface{}]interface{}
+}
+
+// networkingReleases returns the names of the releases that the
+// registered backends add.
+func (t *Transformer) networkingReleases() map[string]bool {
+	releases := map[string]bool{}
+	for _, name := range BackendNames() {
+		backend, ok := LookupBackend(name)
+		if !ok {
+			continue
+		}
+		for _, release := range backend.Releases(t) {
+			releases[release.Name] = true
+		}
+	}
+	return releases
+}
+
+func inspectDatabase(manifest *Manifest) (*DatabaseInfo, error) {
+	var database map[interface{}]interface{}
+	for _, path := range []string{"ducati.daemon.database", "connet.daemon.database"} {
+		val, err := lookupProperty(manifest.Properties, path)
+		if err != nil {
+			return nil, err
+		}
+		if db, ok := val.(map[interface{}]interface{}); ok {
+			database = db
+			break
+		}
+	}
+	if database == nil {
+		return nil, nil
+	}
+
+	setting := func(key string) string {
+		if val, ok := database[key]; ok && val != nil {
+			return fmt.Sprint(val)
+		}
+		return ""
+	}
+	info := &DatabaseInfo{
+		Host:              setting("host"),
+		Port:              setting("port"),
+		Name:              setting("name"),
+		Username:          setting("username"),
+		SSLMode:           setting("ssl_mode"),
+		PasswordSet:       setting("password") != "",
+		Location:          "external, not part of the manifest",
+		CredentialsSource: "properties.ducati.daemon.database; the user must already exist",
+	}
+
+	if dbJob := manifest.Job("ducati_db"); dbJob != nil {
+		info.Location = "ducati_db job"
+		for _, template := range dbJob.Templates {
+			switch {
+			case template.Name == "consul_agent":
+				info.Location = "ducati_db job, found through consul"
+			case template.Name == "postgres" && template.Extra["provides"] != nil:
+				info.Location = "ducati_db job, found through a BOSH DNS alias"
+			}
+		}
+	}
+
+	roles, err := lookupProperty(manifest.Properties, "ducati.database.roles")
+	if err != nil {
+		return nil, err
+	}
+	roleList, _ := roles.([]interface{})
+	for _, role := range roleList {
+		if r, ok := role.(map[interface{}]interface{}); ok && fmt.Sprint(r["name"]) == info.Username {
+			info.CredentialsSource = "properties.ducati.database.roles, created by the ducati_db postgres job"
+		}
+	}
+	return info, nil
+}
+
+func inspectGarden(scope string, props map[interface{}]interface{}) (*GardenInfo, error) {
+	val, err := lookupProperty(props, "garden")
+	if err != nil {
+		return nil, err
+	}
+	garden, ok := val.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	info := &GardenInfo{Scope: scope}
+	if plugin, ok := garden["network_plugin"]; ok && plugin != nil {
+		info.NetworkPlugin = fmt.Sprint(plugin)
+	}
+	for key, list := range map[string]*[]string{
+		"network_plugin_extra_args": &info.NetworkPluginExtraArgs,
+		"shared_mounts":             &info.SharedMounts,
+		"dns_servers":               &info.DNSServers,
+	} {
+		*list, err = mergeStringList(garden[key], nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s properties.garden.%s: %s", scope, key, err)
+		}
+	}
+	if info.NetworkPlugin == "" && len(info.NetworkPluginExtraArgs) == 0 &&
+		len(info.SharedMounts) == 0 && len(info.DNSServers) == 0 {
+		return nil, nil
+	}
+	return info, nil
+}
+
+func inspectRoutes(scope string, props map[interface{}]interface{}) ([]RouteInfo, error) {
+	val, err := lookupProperty(props, "route_registrar.routes")
+	if err != nil {
+		return nil, err
+	}
+	routes, _ := val.([]interface{})
+
+	infos := []RouteInfo{}
+	for _, route := range routes {
+		r, ok := route.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		info := RouteInfo{Scope: scope, Name: fmt.Sprint(r["name"]), URIs: []string{}}
+		if port, ok := r["port"]; ok {
+			info.Port = fmt.Sprint(port)
+		}
+		info.URIs, err = mergeStringList(r["uris"], nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s route %s uris: %s", scope, info.Name, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
@@ -0,0 +1,172 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Inspect", func() {
+	var (
+		transformer *ducatify.Transformer
+		vanilla     map[interface{}]interface{}
+	)
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		transformer.DeriveGardenDNSServers = false
+		vanilla = map[interface{}]interface{}{
+			"releases": []interface{}{
+				map[interface{}]interface{}{"name": "diego", "version": "latest"},
+			},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "instances": 1, "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cc_bridge_z1", "instances": 2, "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z1", "instances": 3, "templates": []interface{}{}},
+			},
+			"properties": map[interface{}]interface{}{
+				"diego": map[interface{}]interface{}{
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	inspect := func(manifest map[interface{}]interface{}) *ducatify.Inspection {
+		inspection, err := ducatify.New().Inspect(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		return inspection
+	}
+
+	It("summarizes the networking configuration of a transformed manifest", func() {
+		manifest, err := transformer.Transform(vanilla, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+
+		inspection := inspect(manifest)
+		Expect(inspection.SchemaVersion).To(Equal(ducatify.SchemaVersion))
+		Expect(inspection.Releases).To(Equal([]ducatify.ReleaseInfo{{Name: "ducati", Version: "latest"}}))
+		Expect(inspection.Jobs).To(Equal([]ducatify.JobInfo{
+			{Name: "ducati_db", Instances: 1, Templates: []string{"postgres"}},
+			{Name: "cc_bridge_z1", Instances: 2, Templates: []string{"connet"}},
+			{Name: "cell_z1", Instances: 3, Templates: []string{"ducati"}},
+			{Name: "ducati-acceptance", Instances: 1, Templates: []string{"acceptance-with-cf"}},
+		}))
+		Expect(inspection.Database).To(Equal(&ducatify.DatabaseInfo{
+			Host:              "ducati-db.service.cf.internal",
+			Port:              "5432",
+			Name:              "ducati",
+			Username:          "ducati_daemon",
+			SSLMode:           "disable",
+			PasswordSet:       true,
+			Location:          "ducati_db job, found through consul",
+			CredentialsSource: "properties.ducati.database.roles, created by the ducati_db postgres job",
+		}))
+		Expect(inspection.Garden).To(Equal([]ducatify.GardenInfo{{
+			Scope:                  "global",
+			NetworkPlugin:          "/var/vcap/packages/ducati/bin/guardian-cni-adapter",
+			NetworkPluginExtraArgs: []string{"--configFile=/var/vcap/jobs/ducati/config/adapter.json"},
+			SharedMounts:           []string{"/var/vcap/data/ducati/container-netns"},
+			DNSServers:             []string{"192.168.255.254"},
+		}}))
+		Expect(inspection.NsyncNetworkID).To(Equal("ducati-overlay"))
+		Expect(inspection.Routes).To(Equal([]ducatify.RouteInfo{{
+			Scope: "cc_bridge_z1",
+			Name:  "connet",
+			Port:  "4002",
+			URIs:  []string{"connet.some.system.domain"},
+		}}))
+	})
+
+	It("describes a BOSH DNS alias and an external database", func() {
+		transformer.ServiceDiscovery = ducatify.ServiceDiscoveryBOSHDNS
+		manifest, err := transformer.Transform(vanilla, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inspect(manifest).Database.Location).To(Equal("ducati_db job, found through a BOSH DNS alias"))
+
+		transformer = ducatify.New(ducatify.WithExternalDB("db.example.com", 5433))
+		manifest, err = transformer.Transform(vanilla, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+		database := inspect(manifest).Database
+		Expect(database.Host).To(Equal("db.example.com"))
+		Expect(database.Location).To(Equal("external, not part of the manifest"))
+		Expect(database.CredentialsSource).To(ContainSubstring("the user must already exist"))
+	})
+
+	It("lists job-level garden settings separately", func() {
+		transformer.GardenOverrides = map[string]ducatify.GardenOverride{
+			"cell_z1": {DNSServers: []string{"10.0.0.2"}},
+		}
+		manifest, err := transformer.Transform(vanilla, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+
+		garden := inspect(manifest).Garden
+		Expect(garden).To(HaveLen(2))
+		Expect(garden[1].Scope).To(Equal("cell_z1"))
+		Expect(garden[1].DNSServers).To(Equal([]string{"10.0.0.2"}))
+	})
+
+	It("reports nothing for a manifest without container networking", func() {
+		inspection := inspect(vanilla)
+		Expect(inspection.SchemaVersion).To(Equal(0))
+		Expect(inspection.Releases).To(BeEmpty())
+		Expect(inspection.Jobs).To(BeEmpty())
+		Expect(inspection.Database).To(BeNil())
+		Expect(inspection.Garden).To(BeEmpty())
+		Expect(inspection.NsyncNetworkID).To(BeEmpty())
+	})
+
+	Describe("the schema version", func() {
+		var manifest map[interface{}]interface{}
+
+		BeforeEach(func() {
+			var err error
+			manifest, err = transformer.Transform(vanilla, map[interface{}]interface{}{}, "some.system.domain")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		setMarker := func(marker interface{}) {
+			manifest["properties"].(map[interface{}]interface{})["ducatify"].(map[interface{}]interface{})["schema_version"] = marker
+		}
+
+		It("is read from a manifest parsed from yaml", func() {
+			bytes, err := candiedyaml.Marshal(manifest)
+			Expect(err).NotTo(HaveOccurred())
+			var parsed map[interface{}]interface{}
+			Expect(candiedyaml.Unmarshal(bytes, &parsed)).To(Succeed())
+
+			inspection := inspect(parsed)
+			Expect(inspection.SchemaVersion).To(Equal(ducatify.SchemaVersion))
+			Expect(inspection.SchemaVersionProblem).To(BeEmpty())
+		})
+
+		It("is read from a marker of any integer type", func() {
+			setMarker(int64(ducatify.SchemaVersion))
+			Expect(inspect(manifest).SchemaVersion).To(Equal(ducatify.SchemaVersion))
+		})
+
+		It("reports a malformed marker and still summarizes the rest", func() {
+			setMarker("two")
+
+			inspection := inspect(manifest)
+			Expect(inspection.SchemaVersion).To(Equal(0))
+			Expect(inspection.SchemaVersionProblem).To(ContainSubstring("schema_version to be a positive integer, got two"))
+			Expect(inspection.NsyncNetworkID).To(Equal("ducati-overlay"))
+		})
+
+		It("reports a version newer than ducatify knows", func() {
+			setMarker(ducatify.SchemaVersion + 1)
+
+			inspection := inspect(manifest)
+			Expect(inspection.SchemaVersion).To(Equal(ducatify.SchemaVersion + 1))
+			Expect(inspection.SchemaVersionProblem).To(ContainSubstring("newer than"))
+		})
+	})
+
+	It("leaves the manifest untouched", func() {
+		original := copyValue(vanilla)
+		inspect(vanilla)
+		Expect(vanilla).To(Equal(original))
+	})
+})
@@ -0,0 +1,611 @@
+package ducatify
+
+import "fmt"
+
+// Manifest is a BOSH v1 deployment manifest. The parts ducatify works with
+// are typed; every other key is kept in Extra and written back unchanged,
+// so that decoding and encoding a manifest is lossless.
+type Manifest struct {
+	Releases      []*Release
+	Jobs          []*Job
+	Networks      []*Network
+	ResourcePools []*ResourcePool
+	Properties    map[interface{}]interface{}
+	Extra         map[interface{}]interface{}
+
+	keys map[string]bool
+}
+
+type Release struct {
+	Name string
+	// Version is usually "latest" or a string, but BOSH also accepts
+	// plain numbers.
+	Version interface{}
+	Extra   map[interface{}]interface{}
+
+	keys map[string]bool
+}
+
+type Job struct {
+	Name           string
+	Instances      int
+	Lifecycle      string
+	PersistentDisk int
+	ResourcePool   string
+	AZs            []string
+	Networks       []*JobNetwork
+	Templates      []*Template
+	Properties     map[interface{}]interface{}
+	Extra          map[interface{}]interface{}
+
+	keys map[string]bool
+}
+
+// JobNetwork is a network a job is placed on. Static IPs and default
+// gateway settings are kept in Extra.
+type JobNetwork struct {
+	Name  string
+	Extra map[interface{}]interface{}
+}
+
+type Template struct {
+	Name    string
+	Release string
+	Extra   map[interface{}]interface{}
+
+	keys map[string]bool
+}
+
+type Network struct {
+	Name    string
+	Subnets []*Subnet
+	Extra   map[interface{}]interface{}
+
+	keys map[string]bool
+}
+
+type Subnet struct {
+	Range string
+	DNS   []string
+	Extra map[interface{}]interface{}
+
+	keys map[string]bool
+}
+
+type ResourcePool struct {
+	Name  string
+	Extra map[interface{}]interface{}
+}
+
+// DecodeManifest converts a manifest as parsed from yaml into a Manifest,
+// returning an error that names the offending key when a part ducatify
+// works with has an unexpected type. Property maps are shared with raw,
+// not copied.
+func DecodeManifest(raw map[interface{}]interface{}) (*Manifest, error) {
+	d := newDecoder("", raw)
+	m := &Manifest{}
+
+	err := d.list("releases", func(path string, item interface{}) error {
+		release, err := decodeRelease(path, item)
+		m.Releases = append(m.Releases, release)
+		return err
+	}, func() { m.Releases = []*Release{} })
+	if err != nil {
+		return nil, err
+	}
+	err = d.list("jobs", func(path string, item interface{}) error {
+		job, err := decodeJob(path, item)
+		m.Jobs = append(m.Jobs, job)
+		return err
+	}, func() { m.Jobs = []*Job{} })
+	if err != nil {
+		return nil, err
+	}
+	err = d.list("networks", func(path string, item interface{}) error {
+		network, err := decodeNetwork(path, item)
+		m.Networks = append(m.Networks, network)
+		return err
+	}, func() { m.Networks = []*Network{} })
+	if err != nil {
+		return nil, err
+	}
+	err = d.list("resource_pools", func(path string, item interface{}) error {
+		pool, err := decodeResourcePool(path, item)
+		m.ResourcePools = append(m.ResourcePools, pool)
+		return err
+	}, func() { m.ResourcePools = []*ResourcePool{} })
+	if err != nil {
+		return nil, err
+	}
+	m.Properties, err = d.properties()
+	if err != nil {
+		return nil, err
+	}
+
+	m.Extra, m.keys = d.rest()
+	return m, nil
+}
+
+// Map converts the Manifest back into the form yaml packages marshal.
+func (m *Manifest) Map() map[interface{}]interface{} {
+	e := newEncoder(m.Extra, m.keys)
+	if m.Releases != nil || e.keys["releases"] {
+		releases := []interface{}{}
+		for _, release := range m.Releases {
+			releases = append(releases, release.encode())
+		}
+		e.out["releases"] = releases
+	}
+	if m.Jobs != nil || e.keys["jobs"] {
+		jobs := []interface{}{}
+		for _, job := range m.Jobs {
+			jobs = append(jobs, job.encode())
+		}
+		e.out["jobs"] = jobs
+	}
+	if m.Networks != nil || e.keys["networks"] {
+		networks := []interface{}{}
+		for _, network := range m.Networks {
+			networks = append(networks, network.encode())
+		}
+		e.out["networks"] = networks
+	}
+	if m.ResourcePools != nil || e.keys["resource_pools"] {
+		pools := []interface{}{}
+		for _, pool := range m.ResourcePools {
+			pools = append(pools, encodeNamed(pool.Name, pool.Extra))
+		}
+		e.out["resource_pools"] = pools
+	}
+	e.properties(m.Properties)
+	return e.out
+}
+
+// Copy returns a deep copy of the Manifest that shares nothing with it.
+func (m *Manifest) Copy() (*Manifest, error) {
+	return DecodeManifest(deepCopy(m.Map()).(map[interface{}]interface{}))
+}
+
+// hasRelease reports whether the manifest has a release with the given
+// name.
+func (m *Manifest) hasRelease(name string) bool {
+	for _, release := range m.Releases {
+		if release.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Job returns the job with the given name, or nil.
+func (m *Manifest) Job(name string) *Job {
+	for _, job := range m.Jobs {
+		if job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// Network returns the network with the given name, or nil.
+func (m *Manifest) Network(name string) *Network {
+	for _, network := range m.Networks {
+		if network.Name == name {
+			return network
+		}
+	}
+	return nil
+}
+
+// HasTemplate reports whether the job runs a template with the given name.
+func (j *Job) HasTemplate(name string) bool {
+	for _, template := range j.Templates {
+		if template.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeRelease(path string, raw interface{}) (*Release, error) {
+	d, err := newItemDecoder(path, raw)
+	if err != nil {
+		return nil, err
+	}
+	release := &Release{}
+	release.Name, err = d.name()
+	if err != nil {
+		return nil, err
+	}
+	if version, ok := d.take("version"); ok {
+		release.Version = version
+	}
+	release.Extra, release.keys = d.rest()
+	return release, nil
+}
+
+func (r *Release) encode() map[interface{}]interface{} {
+	e := newEncoder(r.Extra, r.keys)
+	e.out["name"] = r.Name
+	if r.Version != nil || e.keys["version"] {
+		e.out["version"] = r.Version
+	}
+	return e.out
+}
+
+func decodeJob(path string, raw interface{}) (*Job, error) {
+	d, err := newItemDecoder(path, raw)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	job.Name, err = d.name()
+	if err != nil {
+		return nil, err
+	}
+	// name the job in errors, the index alone is hard to find in a big manifest
+	d.path = fmt.Sprintf("%s (%s)", path, job.Name)
+
+	if job.Instances, err = d.int("instances"); err != nil {
+		return nil, err
+	}
+	if job.Lifecycle, err = d.string("lifecycle"); err != nil {
+		return nil, err
+	}
+	if job.PersistentDisk, err = d.int("persistent_disk"); err != nil {
+		return nil, err
+	}
+	if job.ResourcePool, err = d.string("resource_pool"); err != nil {
+		return nil, err
+	}
+	if job.AZs, err = d.strings("azs"); err != nil {
+		return nil, err
+	}
+	err = d.list("networks", func(path string, item interface{}) error {
+		network, err := newItemDecoder(path, item)
+		if err != nil {
+			return err
+		}
+		name, err := network.name()
+		if err != nil {
+			return err
+		}
+		extra, _ := network.rest()
+		job.Networks = append(job.Networks, &JobNetwork{Name: name, Extra: extra})
+		return nil
+	}, func() { job.Networks = []*JobNetwork{} })
+	if err != nil {
+		return nil, err
+	}
+	err = d.list("templates", func(path string, item interface{}) error {
+		template, err := newItemDecoder(path, item)
+		if err != nil {
+			return err
+		}
+		name, err := template.name()
+		if err != nil {
+			return err
+		}
+		release, err := template.string("release")
+		if err != nil {
+			return err
+		}
+		extra, keys := template.rest()
+		job.Templates = append(job.Templates, &Template{Name: name, Release: release, Extra: extra, keys: keys})
+		return nil
+	}, func() { job.Templates = []*Template{} })
+	if err != nil {
+		return nil, err
+	}
+	if job.Properties, err = d.properties(); err != nil {
+		return nil, err
+	}
+	job.Extra, job.keys = d.rest()
+	return job, nil
+}
+
+func (j *Job) encode() map[interface{}]interface{} {
+	e := newEncoder(j.Extra, j.keys)
+	e.out["name"] = j.Name
+	e.int("instances", j.Instances)
+	e.string("lifecycle", j.Lifecycle)
+	e.int("persistent_disk", j.PersistentDisk)
+	e.string("resource_pool", j.ResourcePool)
+	e.strings("azs", j.AZs)
+	if j.Networks != nil {
+		networks := []interface{}{}
+		for _, network := range j.Networks {
+			networks = append(networks, encodeNamed(network.Name, network.Extra))
+		}
+		e.out["networks"] = networks
+	}
+	if j.Templates != nil {
+		templates := []interface{}{}
+		for _, template := range j.Templates {
+			templates = append(templates, template.encode())
+		}
+		e.out["templates"] = templates
+	}
+	e.properties(j.Properties)
+	return e.out
+}
+
+func (t *Template) encode() map[interface{}]interface{} {
+	e := newEncoder(t.Extra, t.keys)
+	e.out["name"] = t.Name
+	e.string("release", t.Release)
+	return e.out
+}
+
+func decodeNetwork(path string, raw interface{}) (*Network, error) {
+	d, err := newItemDecoder(path, raw)
+	if err != nil {
+		return nil, err
+	}
+	network := &Network{}
+	network.Name, err = d.name()
+	if err != nil {
+		return nil, err
+	}
+	d.path = fmt.Sprintf("%s (%s)", path, network.Name)
+
+	err = d.list("subnets", func(path string, item interface{}) error {
+		subnetDecoder, err := newItemDecoder(path, item)
+		if err != nil {
+			return err
+		}
+		subnet := &Subnet{}
+		if subnet.Range, err = subnetDecoder.string("range"); err != nil {
+			return err
+		}
+		if subnet.DNS, err = subnetDecoder.strings("dns"); err != nil {
+			return err
+		}
+		subnet.Extra, subnet.keys = subnetDecoder.rest()
+		network.Subnets = append(network.Subnets, subnet)
+		return nil
+	}, func() { network.Subnets = []*Subnet{} })
+	if err != nil {
+		return nil, err
+	}
+	network.Extra, network.keys = d.rest()
+	return network, nil
+}
+
+func (n *Network) encode() map[interface{}]interface{} {
+	e := newEncoder(n.Extra, n.keys)
+	e.out["name"] = n.Name
+	if n.Subnets != nil || e.keys["subnets"] {
+		subnets := []interface{}{}
+		for _, subnet := range n.Subnets {
+			subnetEncoder := newEncoder(subnet.Extra, subnet.keys)
+			subnetEncoder.string("range", subnet.Range)
+			subnetEncoder.strings("dns", subnet.DNS)
+			subnets = append(subnets, subnetEncoder.out)
+		}
+		e.out["subnets"] = subnets
+	}
+	return e.out
+}
+
+func decodeResourcePool(path string, raw interface{}) (*ResourcePool, error) {
+	d, err := newItemDecoder(path, raw)
+	if err != nil {
+		return nil, err
+	}
+	name, err := d.name()
+	if err != nil {
+		return nil, err
+	}
+	extra, _ := d.rest()
+	return &ResourcePool{Name: name, Extra: extra}, nil
+}
+
+func encodeNamed(name string, extra map[interface{}]interface{}) map[interface{}]interface{} {
+	encoded := newEncoder(extra, nil).out
+	encoded["name"] = name
+	return encoded
+}
+
+// decoder takes the typed keys out of a copy of a yaml map, recording
+// which were present, and leaves the rest for the Extra field. Keys holding
+// nil are left in place too, so that they are written back as nil rather
+// than as the zero value of their field.
+type decoder struct {
+	path string
+	raw  map[interface{}]interface{}
+	keys map[string]bool
+}
+
+func newDecoder(path string, raw map[interface{}]interface{}) *decoder {
+	copied := make(map[interface{}]interface{}, len(raw))
+	for key, val := range raw {
+		copied[key] = val
+	}
+	return &decoder{path: path, raw: copied, keys: map[string]bool{}}
+}
+
+func newItemDecoder(path string, raw interface{}) (*decoder, error) {
+	m, ok := raw.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a map, got %s", path, describeType(raw))
+	}
+	return newDecoder(path, m), nil
+}
+
+func (d *decoder) keyPath(key string) string {
+	if d.path == "" {
+		return key
+	}
+	return d.path + "." + key
+}
+
+func (d *decoder) take(key string) (interface{}, bool) {
+	val, ok := d.raw[key]
+	if !ok || val == nil {
+		return nil, false
+	}
+	delete(d.raw, key)
+	d.keys[key] = true
+	return val, true
+}
+
+func (d *decoder) name() (string, error) {
+	if _, ok := d.raw["name"]; !ok {
+		return "", fmt.Errorf("%s: missing name", d.path)
+	}
+	name, err := d.string("name")
+	if err == nil && name == "" {
+		err = fmt.Errorf("%s: missing name", d.path)
+	}
+	return name, err
+}
+
+func (d *decoder) string(key string) (string, error) {
+	val, ok := d.take(key)
+	if !ok {
+		return "", nil
+	}
+	s, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("%s: expected a string, got %s", d.keyPath(key), describeType(val))
+	}
+	return s, nil
+}
+
+func (d *decoder) int(key string) (int, error) {
+	val, ok := d.take(key)
+	if !ok {
+		return 0, nil
+	}
+	if i, ok := integerValue(val); ok {
+		return int(i), nil
+	}
+	return 0, fmt.Errorf("%s: expected an integer, got %s", d.keyPath(key), describeType(val))
+}
+
+func (d *decoder) strings(key string) ([]string, error) {
+	val, ok := d.take(key)
+	if !ok {
+		return nil, nil
+	}
+	items, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a list, got %s", d.keyPath(key), describeType(val))
+	}
+	strs := []string{}
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d]: expected a string, got %s", d.keyPath(key), i, describeType(item))
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// list calls decodeItem for every item of the list under key, and empty
+// when the list is present but has no items.
+func (d *decoder) list(key string, decodeItem func(path string, item interface{}) error, empty func()) error {
+	val, ok := d.take(key)
+	if !ok {
+		return nil
+	}
+	items, ok := val.([]interface{})
+	if !ok {
+		return fmt.Errorf("%s: expected a list, got %s", d.keyPath(key), describeType(val))
+	}
+	if len(items) == 0 {
+		empty()
+	}
+	for i, item := range items {
+		err := decodeItem(fmt.Sprintf("%s[%d]", d.keyPath(key), i), item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) properties() (map[interface{}]interface{}, error) {
+	val, ok := d.take("properties")
+	if !ok {
+		return nil, nil
+	}
+	props, ok := val.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a map, got %s", d.keyPath("properties"), describeType(val))
+	}
+	return props, nil
+}
+
+func (d *decoder) rest() (map[interface{}]interface{}, map[string]bool) {
+	if len(d.raw) == 0 {
+		return nil, d.keys
+	}
+	return d.raw, d.keys
+}
+
+// encoder builds a yaml map from a typed value, writing a key when its
+// field is set or the key was present when the value was decoded.
+type encoder struct {
+	out  map[interface{}]interface{}
+	keys map[string]bool
+}
+
+func newEncoder(extra map[interface{}]interface{}, keys map[string]bool) *encoder {
+	out := make(map[interface{}]interface{}, len(extra))
+	for key, val := range extra {
+		out[key] = val
+	}
+	if keys == nil {
+		keys = map[string]bool{}
+	}
+	return &encoder{out: out, keys: keys}
+}
+
+func (e *encoder) string(key, val string) {
+	if val != "" || e.keys[key] {
+		e.out[key] = val
+	}
+}
+
+func (e *encoder) int(key string, val int) {
+	if val != 0 || e.keys[key] {
+		e.out[key] = val
+	}
+}
+
+func (e *encoder) strings(key string, val []string) {
+	if val == nil {
+		if e.keys[key] {
+			e.out[key] = nil
+		}
+		return
+	}
+	items := []interface{}{}
+	for _, s := range val {
+		items = append(items, s)
+	}
+	e.out[key] = items
+}
+
+func (e *encoder) properties(props map[interface{}]interface{}) {
+	if props != nil || e.keys["properties"] {
+		e.out["properties"] = props
+	}
+}
+
+func describeType(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "nothing"
+	case string:
+		return fmt.Sprintf("string %q", val)
+	case map[interface{}]interface{}:
+		return "a map"
+	case []interface{}:
+		return "a list"
+	}
+	return fmt.Sprintf("%T %v", val, val)
+}
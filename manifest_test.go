@@ -0,0 +1,153 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manifest", func() {
+	var raw map[interface{}]interface{}
+
+	BeforeEach(func() {
+		raw = map[interface{}]interface{}{
+			"name":          "cf-warden-diego",
+			"director_uuid": "some-uuid",
+			"releases": []interface{}{
+				map[interface{}]interface{}{"name": "diego", "version": 1234, "url": "some-url"},
+			},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{
+					"name":      "cell_z1",
+					"instances": 0,
+					"azs":       []interface{}{"z1"},
+					"networks": []interface{}{
+						map[interface{}]interface{}{"name": "diego1", "static_ips": []interface{}{"10.0.0.5"}},
+					},
+					"templates": []interface{}{
+						map[interface{}]interface{}{"name": "rep", "release": "diego", "consumes": "some-link"},
+					},
+					"update": map[interface{}]interface{}{"serial": true},
+				},
+				map[interface{}]interface{}{"name": "empty_z1", "templates": []interface{}{}},
+			},
+			"networks": []interface{}{
+				map[interface{}]interface{}{
+					"name": "diego1",
+					"type": "manual",
+					"subnets": []interface{}{
+						map[interface{}]interface{}{
+							"range":            "10.0.0.0/24",
+							"dns":              []interface{}{"10.0.0.2"},
+							"cloud_properties": map[interface{}]interface{}{"name": "random"},
+						},
+					},
+				},
+			},
+			"resource_pools": []interface{}{
+				map[interface{}]interface{}{"name": "cell_z1", "stemcell": map[interface{}]interface{}{"name": "some-stemcell"}},
+			},
+			"properties": map[interface{}]interface{}{"diego": map[interface{}]interface{}{}},
+		}
+	})
+
+	It("decodes the parts ducatify works with", func() {
+		manifest, err := ducatify.DecodeManifest(raw)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(manifest.Releases[0].Version).To(Equal(1234))
+		cell := manifest.Job("cell_z1")
+		Expect(cell.AZs).To(Equal([]string{"z1"}))
+		Expect(cell.Networks[0].Name).To(Equal("diego1"))
+		Expect(cell.HasTemplate("rep")).To(BeTrue())
+		Expect(manifest.Network("diego1").Subnets[0].DNS).To(Equal([]string{"10.0.0.2"}))
+		Expect(manifest.ResourcePools[0].Name).To(Equal("cell_z1"))
+	})
+
+	It("encodes back to the same manifest, including fields it does not know", func() {
+		manifest, err := ducatify.DecodeManifest(raw)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Map()).To(Equal(raw))
+	})
+
+	It("encodes a manifest parsed from yaml back to the same manifest", func() {
+		var parsed map[interface{}]interface{}
+		Expect(candiedyaml.Unmarshal([]byte(`---
+name: some-deployment
+releases:
+- name: diego
+  version: ~
+jobs:
+- name: cell_z1
+  instances: 2
+  lifecycle: ~
+  resource_pool: ""
+  azs: [z1, z1]
+  networks: ~
+  templates:
+  - name: rep
+    release: ""
+  - name: consul_agent
+    release: ~
+  properties: ~
+networks:
+- name: diego1
+  subnets: ~
+- name: diego2
+  subnets:
+  - range: ~
+    dns: [10.0.0.2, 10.0.0.2, 10.0.0.3]
+`), &parsed)).To(Succeed())
+		expected := copyValue(parsed)
+
+		manifest, err := ducatify.DecodeManifest(parsed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Job("cell_z1").AZs).To(Equal([]string{"z1", "z1"}))
+		Expect(manifest.Network("diego2").Subnets[0].DNS).To(Equal([]string{"10.0.0.2", "10.0.0.2", "10.0.0.3"}))
+
+		Expect(normalizeIntegers(manifest.Map())).To(Equal(normalizeIntegers(expected)))
+	})
+
+	It("writes changes to the typed fields back", func() {
+		manifest, err := ducatify.DecodeManifest(raw)
+		Expect(err).NotTo(HaveOccurred())
+
+		manifest.Job("cell_z1").Templates = append(manifest.Job("cell_z1").Templates,
+			&ducatify.Template{Name: "ducati", Release: "ducati"})
+
+		jobs := manifest.Map()["jobs"].([]interface{})
+		Expect(jobs[0].(map[interface{}]interface{})["templates"]).To(Equal([]interface{}{
+			map[interface{}]interface{}{"name": "rep", "release": "diego", "consumes": "some-link"},
+			map[interface{}]interface{}{"name": "ducati", "release": "ducati"},
+		}))
+	})
+
+	Context("when a part has the wrong type", func() {
+		It("names the job and key", func() {
+			raw["jobs"].([]interface{})[0].(map[interface{}]interface{})["instances"] = "two"
+			_, err := ducatify.DecodeManifest(raw)
+			Expect(err).To(MatchError(`jobs[0] (cell_z1).instances: expected an integer, got string "two"`))
+		})
+
+		It("names the position of items that are not maps", func() {
+			raw["networks"].([]interface{})[0].(map[interface{}]interface{})["subnets"] = []interface{}{"10.0.0.0/24"}
+			_, err := ducatify.DecodeManifest(raw)
+			Expect(err).To(MatchError(`networks[0] (diego1).subnets[0]: expected a map, got string "10.0.0.0/24"`))
+		})
+
+		It("rejects items without a name", func() {
+			raw["releases"] = []interface{}{map[interface{}]interface{}{"version": "latest"}}
+			_, err := ducatify.DecodeManifest(raw)
+			Expect(err).To(MatchError("releases[0]: missing name"))
+		})
+
+		It("fails Transform before changing anything", func() {
+			raw["jobs"] = "not-a-list"
+			_, err := ducatify.New().Transform(raw, map[interface{}]interface{}{}, "some.system.domain")
+			Expect(err).To(MatchError("decoding manifest: jobs: expected a list, got string \"not-a-list\""))
+			Expect(raw["releases"]).To(HaveLen(1))
+		})
+	})
+})
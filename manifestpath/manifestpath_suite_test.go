@@ -0,0 +1,13 @@
+package manifestpath_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestManifestpath(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Manifestpath Suite")
+}
@@ -0,0 +1,353 @@
+// Package manifestpath queries and edits yaml documents, such as BOSH
+// manifests, by path.
+//
+// A path is a list of segments separated by "/", or by "." when it
+// contains no "/", e.g. "jobs/name=cell_z1/templates" or
+// "properties.diego.nsync.network_id". A segment selects
+//
+//	key         the value of a map key
+//	3           the item of a list at that index
+//	field=value the first map in a list whose field has that value
+//
+// Documents are made of map[interface{}]interface{} and []interface{}
+// values, as yaml packages decode them. Maps are edited in place; lists
+// that change length are replaced in their parent.
+package manifestpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NotFoundError is returned when a path does not lead to a value.
+type NotFoundError struct {
+	Path string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("no value at %s", e.Path)
+}
+
+// IsNotFound reports whether err is a *NotFoundError.
+func IsNotFound(err error) bool {
+	_, ok := err.(*NotFoundError)
+	return ok
+}
+
+type segment struct {
+	raw   string
+	field string
+	value string
+	index int
+}
+
+func (s segment) isSelector() bool {
+	return s.field != ""
+}
+
+// Path is a parsed path expression.
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+// Parse parses a path expression. The empty path refers to the whole
+// document.
+func Parse(expr string) (Path, error) {
+	path := Path{expr: expr}
+	if expr == "" {
+		return path, nil
+	}
+
+	separator := "."
+	if strings.Contains(expr, "/") {
+		separator = "/"
+	}
+
+	for _, raw := range strings.Split(strings.Trim(expr, "/"), separator) {
+		if raw == "" {
+			return Path{}, fmt.Errorf("empty segment in path %q", expr)
+		}
+		seg := segment{raw: raw, index: -1}
+		if i := strings.Index(raw, "="); i >= 0 {
+			seg.field, seg.value = raw[:i], raw[i+1:]
+			if seg.field == "" {
+				return Path{}, fmt.Errorf("selector %q in path %q has no field", raw, expr)
+			}
+		} else if index, err := strconv.Atoi(raw); err == nil && index >= 0 {
+			seg.index = index
+		}
+		path.segments = append(path.segments, seg)
+	}
+	return path, nil
+}
+
+func (p Path) String() string {
+	return p.expr
+}
+
+// prefix renders the first n segments, for error messages.
+func (p Path) prefix(n int) string {
+	raws := []string{}
+	for _, seg := range p.segments[:n] {
+		raws = append(raws, seg.raw)
+	}
+	if strings.Contains(p.expr, "/") {
+		return strings.Join(raws, "/")
+	}
+	return strings.Join(raws, ".")
+}
+
+// Get returns the value at expr.
+func Get(doc interface{}, expr string) (interface{}, error) {
+	path, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return path.Get(doc)
+}
+
+// Set stores value at expr, creating any missing maps on the way. List
+// items can be replaced but not created; use Append for that.
+func Set(doc interface{}, expr string, value interface{}) error {
+	path, err := Parse(expr)
+	if err != nil {
+		return err
+	}
+	return path.Set(doc, value)
+}
+
+// Append adds values to the end of the list at expr, creating the list if
+// it does not exist yet.
+func Append(doc interface{}, expr string, values ...interface{}) error {
+	path, err := Parse(expr)
+	if err != nil {
+		return err
+	}
+	return path.Append(doc, values...)
+}
+
+// Delete removes the map key or list item at expr.
+func Delete(doc interface{}, expr string) error {
+	path, err := Parse(expr)
+	if err != nil {
+		return err
+	}
+	return path.Delete(doc)
+}
+
+func (p Path) Get(doc interface{}) (interface{}, error) {
+	val := doc
+	for i := range p.segments {
+		child, ok, err := p.child(val, i)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &NotFoundError{Path: p.prefix(i + 1)}
+		}
+		val = child
+	}
+	return val, nil
+}
+
+func (p Path) Set(doc interface{}, value interface{}) error {
+	if len(p.segments) == 0 {
+		return fmt.Errorf("cannot replace the whole document")
+	}
+	return p.update(doc, func(interface{}, bool) (interface{}, bool, error) {
+		return value, true, nil
+	})
+}
+
+func (p Path) Append(doc interface{}, values ...interface{}) error {
+	if len(p.segments) == 0 {
+		return fmt.Errorf("cannot append to the whole document")
+	}
+	return p.update(doc, func(existing interface{}, exists bool) (interface{}, bool, error) {
+		if !exists || existing == nil {
+			return append([]interface{}{}, values...), true, nil
+		}
+		list, ok := existing.([]interface{})
+		if !ok {
+			return nil, false, fmt.Errorf("expected %s to be a list, got %s", p.expr, describe(existing))
+		}
+		return append(list, values...), true, nil
+	})
+}
+
+func (p Path) Delete(doc interface{}) error {
+	if len(p.segments) == 0 {
+		return fmt.Errorf("cannot delete the whole document")
+	}
+	return p.update(doc, func(existing interface{}, exists bool) (interface{}, bool, error) {
+		if !exists {
+			return nil, false, &NotFoundError{Path: p.expr}
+		}
+		return nil, false, nil
+	})
+}
+
+// change computes the new value at the end of a path from the current one.
+// Returning keep=false removes the value.
+type change func(existing interface{}, exists bool) (value interface{}, keep bool, err error)
+
+// update applies fn to the value at the end of the path. The document root
+// must be a map, since it cannot be replaced.
+func (p Path) update(doc interface{}, fn change) error {
+	if _, ok := doc.(map[interface{}]interface{}); !ok {
+		return fmt.Errorf("expected the document to be a map, got %s", describe(doc))
+	}
+	_, _, err := p.updateAt(doc, 0, fn)
+	return err
+}
+
+// updateAt applies fn below node, which is the value found after i
+// segments, and returns node's replacement.
+func (p Path) updateAt(node interface{}, i int, fn change) (interface{}, bool, error) {
+	seg := p.segments[i]
+	last := i == len(p.segments)-1
+
+	switch container := node.(type) {
+	case map[interface{}]interface{}:
+		if seg.isSelector() {
+			return nil, false, fmt.Errorf("selector %s needs a list, but %s is a map", seg.raw, p.location(i))
+		}
+		key, exists := mapKey(container, seg.raw)
+		existing := container[key]
+
+		var value interface{}
+		keep := true
+		var err error
+		if last {
+			value, keep, err = fn(existing, exists)
+		} else {
+			if !exists || existing == nil {
+				existing = map[interface{}]interface{}{}
+			}
+			value, keep, err = p.updateAt(existing, i+1, fn)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if keep {
+			container[key] = value
+		} else {
+			delete(container, key)
+		}
+		return container, true, nil
+
+	case []interface{}:
+		index, err := p.listIndex(container, i)
+		if err != nil {
+			return nil, false, err
+		}
+		if index < 0 {
+			// list items are never created, only maps
+			return nil, false, &NotFoundError{Path: p.prefix(i + 1)}
+		}
+
+		var value interface{}
+		keep := true
+		if last {
+			value, keep, err = fn(container[index], true)
+		} else {
+			value, keep, err = p.updateAt(container[index], i+1, fn)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if keep {
+			container[index] = value
+			return container, true, nil
+		}
+		return append(container[:index:index], container[index+1:]...), true, nil
+	}
+
+	return nil, false, fmt.Errorf("expected %s to be a map or list, got %s", p.location(i), describe(node))
+}
+
+// child returns the value that segment i selects in node.
+func (p Path) child(node interface{}, i int) (interface{}, bool, error) {
+	seg := p.segments[i]
+	switch container := node.(type) {
+	case map[interface{}]interface{}:
+		if seg.isSelector() {
+			return nil, false, fmt.Errorf("selector %s needs a list, but %s is a map", seg.raw, p.location(i))
+		}
+		key, ok := mapKey(container, seg.raw)
+		return container[key], ok, nil
+	case []interface{}:
+		index, err := p.listIndex(container, i)
+		if err != nil || index < 0 {
+			return nil, false, err
+		}
+		return container[index], true, nil
+	}
+	return nil, false, fmt.Errorf("expected %s to be a map or list, got %s", p.location(i), describe(node))
+}
+
+// listIndex returns the index segment i selects in list, or -1.
+func (p Path) listIndex(list []interface{}, i int) (int, error) {
+	seg := p.segments[i]
+	if seg.isSelector() {
+		for index, item := range list {
+			m, ok := item.(map[interface{}]interface{})
+			if !ok {
+				continue
+			}
+			if val, ok := m[seg.field]; ok && fmt.Sprintf("%v", val) == seg.value {
+				return index, nil
+			}
+		}
+		return -1, nil
+	}
+	if seg.index < 0 {
+		return -1, fmt.Errorf("%s is a list, select an item with an index or field=value instead of %q", p.location(i), seg.raw)
+	}
+	if seg.index >= len(list) {
+		return -1, nil
+	}
+	return seg.index, nil
+}
+
+// location names the value that segment i is applied to.
+func (p Path) location(i int) string {
+	if i == 0 {
+		return "the document"
+	}
+	return p.prefix(i)
+}
+
+// mapKey finds the key of m that a segment names. yaml maps may have
+// integer keys, of whichever integer type the yaml package decodes them
+// to, which are matched by their decimal form.
+func mapKey(m map[interface{}]interface{}, raw string) (interface{}, bool) {
+	if _, ok := m[raw]; ok {
+		return raw, true
+	}
+	if _, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		for key := range m {
+			switch key.(type) {
+			case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+				if fmt.Sprintf("%d", key) == raw {
+					return key, true
+				}
+			}
+		}
+	}
+	return raw, false
+}
+
+func describe(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "nothing"
+	case map[interface{}]interface{}:
+		return "a map"
+	case []interface{}:
+		return "a list"
+	}
+	return fmt.Sprintf("%T", val)
+}
@@ -0,0 +1,168 @@
+package manifestpath_test
+
+import (
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify/manifestpath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Paths", func() {
+	var manifest map[interface{}]interface{}
+
+	BeforeEach(func() {
+		manifest = map[interface{}]interface{}{
+			"jobs": []interface{}{
+				map[interface{}]interface{}{
+					"name":      "cell_z1",
+					"instances": 2,
+					"templates": []interface{}{
+						map[interface{}]interface{}{"name": "rep", "release": "diego"},
+					},
+				},
+				map[interface{}]interface{}{"name": "cell_z2"},
+			},
+			"properties": map[interface{}]interface{}{
+				"diego": map[interface{}]interface{}{
+					"nsync": map[interface{}]interface{}{"network_id": "some-network"},
+				},
+				"ports": map[interface{}]interface{}{80: "http"},
+			},
+		}
+	})
+
+	Describe("Get", func() {
+		It("follows map keys, list selectors and indexes", func() {
+			Expect(manifestpath.Get(manifest, "properties.diego.nsync.network_id")).To(Equal("some-network"))
+			Expect(manifestpath.Get(manifest, "jobs/name=cell_z1/templates/0/release")).To(Equal("diego"))
+			Expect(manifestpath.Get(manifest, "jobs/instances=2/name")).To(Equal("cell_z1"))
+			Expect(manifestpath.Get(manifest, "jobs.1.name")).To(Equal("cell_z2"))
+			Expect(manifestpath.Get(manifest, "properties.ports.80")).To(Equal("http"))
+		})
+
+		It("matches integer keys of a document decoded from yaml", func() {
+			var decoded map[interface{}]interface{}
+			Expect(candiedyaml.Unmarshal([]byte("ports:\n  80: http\n  8443: https\n"), &decoded)).To(Succeed())
+
+			Expect(manifestpath.Get(decoded, "ports.80")).To(Equal("http"))
+			Expect(manifestpath.Get(decoded, "ports/8443")).To(Equal("https"))
+
+			Expect(manifestpath.Set(decoded, "ports.80", "some-service")).To(Succeed())
+			Expect(decoded["ports"]).To(HaveLen(2))
+			Expect(manifestpath.Get(decoded, "ports.80")).To(Equal("some-service"))
+		})
+
+		It("matches integer keys of any integer type", func() {
+			doc := map[interface{}]interface{}{
+				"ports": map[interface{}]interface{}{int64(80): "http", uint64(443): "https"},
+			}
+			Expect(manifestpath.Get(doc, "ports.80")).To(Equal("http"))
+			Expect(manifestpath.Get(doc, "ports.443")).To(Equal("https"))
+		})
+
+		It("returns the whole document for the empty path", func() {
+			Expect(manifestpath.Get(manifest, "")).To(Equal(manifest))
+		})
+
+		It("returns a NotFoundError for missing values", func() {
+			_, err := manifestpath.Get(manifest, "jobs/name=cell_z9/templates")
+			Expect(err).To(MatchError("no value at jobs/name=cell_z9"))
+			Expect(manifestpath.IsNotFound(err)).To(BeTrue())
+
+			_, err = manifestpath.Get(manifest, "properties.diego.bbs")
+			Expect(manifestpath.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("returns an error when the path does not fit the document", func() {
+			_, err := manifestpath.Get(manifest, "properties.diego.nsync.network_id.name")
+			Expect(err).To(MatchError("expected properties.diego.nsync.network_id to be a map or list, got string"))
+			Expect(manifestpath.IsNotFound(err)).To(BeFalse())
+
+			_, err = manifestpath.Get(manifest, "jobs/cell_z1")
+			Expect(err).To(MatchError(ContainSubstring(`jobs is a list, select an item with an index or field=value instead of "cell_z1"`)))
+
+			_, err = manifestpath.Get(manifest, "properties/name=diego")
+			Expect(err).To(MatchError("selector name=diego needs a list, but properties is a map"))
+		})
+
+		It("rejects malformed paths", func() {
+			_, err := manifestpath.Get(manifest, "properties..diego")
+			Expect(err).To(MatchError(`empty segment in path "properties..diego"`))
+
+			_, err = manifestpath.Get(manifest, "jobs/=cell_z1")
+			Expect(err).To(MatchError(ContainSubstring("has no field")))
+		})
+	})
+
+	Describe("Set", func() {
+		It("replaces existing values", func() {
+			Expect(manifestpath.Set(manifest, "properties.diego.nsync.network_id", "ducati-overlay")).To(Succeed())
+			Expect(manifestpath.Get(manifest, "properties.diego.nsync.network_id")).To(Equal("ducati-overlay"))
+
+			Expect(manifestpath.Set(manifest, "jobs/name=cell_z1/templates/0", "some-template")).To(Succeed())
+			Expect(manifestpath.Get(manifest, "jobs/0/templates")).To(Equal([]interface{}{"some-template"}))
+		})
+
+		It("creates missing maps on the way", func() {
+			Expect(manifestpath.Set(manifest, "jobs/name=cell_z2/properties/garden/network_plugin", "some-plugin")).To(Succeed())
+			Expect(manifestpath.Get(manifest, "jobs/1/properties")).To(Equal(map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{"network_plugin": "some-plugin"},
+			}))
+		})
+
+		It("does not create list items", func() {
+			err := manifestpath.Set(manifest, "jobs/name=cell_z9/instances", 1)
+			Expect(manifestpath.IsNotFound(err)).To(BeTrue())
+			Expect(manifest["jobs"]).To(HaveLen(2))
+		})
+
+		It("refuses to go through values that are not maps or lists", func() {
+			err := manifestpath.Set(manifest, "properties.diego.nsync.network_id.name", "x")
+			Expect(err).To(MatchError("expected properties.diego.nsync.network_id to be a map or list, got string"))
+			Expect(manifestpath.Get(manifest, "properties.diego.nsync.network_id")).To(Equal("some-network"))
+		})
+	})
+
+	Describe("Append", func() {
+		It("appends to an existing list, replacing it in its parent", func() {
+			template := map[interface{}]interface{}{"name": "ducati", "release": "ducati"}
+			Expect(manifestpath.Append(manifest, "jobs/name=cell_z1/templates", template)).To(Succeed())
+			Expect(manifestpath.Get(manifest, "jobs/name=cell_z1/templates/1")).To(Equal(template))
+		})
+
+		It("creates a missing list", func() {
+			Expect(manifestpath.Append(manifest, "jobs/name=cell_z2/templates", "a", "b")).To(Succeed())
+			Expect(manifestpath.Get(manifest, "jobs/name=cell_z2/templates")).To(Equal([]interface{}{"a", "b"}))
+		})
+
+		It("fails for values that are not lists", func() {
+			err := manifestpath.Append(manifest, "properties.diego", "x")
+			Expect(err).To(MatchError("expected properties.diego to be a list, got a map"))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("removes map keys", func() {
+			Expect(manifestpath.Delete(manifest, "properties.diego.nsync.network_id")).To(Succeed())
+			Expect(manifestpath.Get(manifest, "properties.diego.nsync")).To(BeEmpty())
+		})
+
+		It("removes list items", func() {
+			Expect(manifestpath.Delete(manifest, "jobs/name=cell_z1")).To(Succeed())
+			Expect(manifest["jobs"]).To(Equal([]interface{}{
+				map[interface{}]interface{}{"name": "cell_z2"},
+			}))
+		})
+
+		It("returns a NotFoundError for missing values, without creating anything", func() {
+			err := manifestpath.Delete(manifest, "properties.garden.network_plugin")
+			Expect(manifestpath.IsNotFound(err)).To(BeTrue())
+			Expect(manifest["properties"]).NotTo(HaveKey("garden"))
+		})
+
+		It("cannot delete the whole document", func() {
+			Expect(manifestpath.Delete(manifest, "")).To(MatchError("cannot delete the whole document"))
+		})
+	})
+})
@@ -0,0 +1,197 @@
+package ducatify
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConflictPolicy describes what to do when a property that ducatify wants
+// to set already holds a different value in the manifest.
+type ConflictPolicy string
+
+const (
+	ConflictWarn      ConflictPolicy = "warn"
+	ConflictFail      ConflictPolicy = "fail"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictMerge     ConflictPolicy = "merge"
+)
+
+// PropertyTrees lists the top-level property trees that ducatify writes and
+// that accept a ConflictPolicy in Transformer.PropertyConflicts.
+var PropertyTrees = []string{"ducati", "connet", "acceptance-with-cf", "flannel"}
+
+func (t *Transformer) propertyConflictPolicy(tree string) ConflictPolicy {
+	if policy, ok := t.PropertyConflicts[tree]; ok {
+		return policy
+	}
+	return ConflictOverwrite
+}
+
+func (t *Transformer) setPropertyTree(props map[interface{}]interface{}, tree string, value interface{}) error {
+	existing, ok := props[tree]
+	if !ok || existing == nil {
+		props[tree] = value
+		return nil
+	}
+
+	switch policy := t.propertyConflictPolicy(tree); policy {
+	case ConflictOverwrite:
+		props[tree] = value
+	case ConflictMerge:
+		props[tree] = deepMerge(existing, value)
+	case ConflictFail:
+		conflicts := findConflicts("properties."+tree, existing, value)
+		if len(conflicts) > 0 {
+			return fmt.Errorf("existing properties conflict with ducatify:\n  %s", strings.Join(conflicts, "\n  "))
+		}
+		props[tree] = deepMerge(existing, value)
+	default:
+		return fmt.Errorf("unsupported conflict policy %q for properties.%s", policy, tree)
+	}
+	return nil
+}
+
+// deepMerge merges generated into existing, recursing into maps present in
+// both. Where the two disagree on a non-map value, generated wins.
+func deepMerge(existing, generated interface{}) interface{} {
+	existingMap, ok := existing.(map[interface{}]interface{})
+	if !ok {
+		return generated
+	}
+	generatedMap, ok := generated.(map[interface{}]interface{})
+	if !ok {
+		return generated
+	}
+
+	merged := map[interface{}]interface{}{}
+	for k, v := range existingMap {
+		merged[k] = v
+	}
+	for k, v := range generatedMap {
+		if e, ok := merged[k]; ok {
+			merged[k] = deepMerge(e, v)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// findConflicts returns a description of every path where existing holds a
+// value different from the one ducatify generated.
+func findConflicts(path string, existing, generated interface{}) []string {
+	existingMap, existingIsMap := existing.(map[interface{}]interface{})
+	generatedMap, generatedIsMap := generated.(map[interface{}]interface{})
+	if existingIsMap && generatedIsMap {
+		keys := []string{}
+		byName := map[string]interface{}{}
+		for k := range generatedMap {
+			name := fmt.Sprintf("%v", k)
+			keys = append(keys, name)
+			byName[name] = k
+		}
+		sort.Strings(keys)
+
+		conflicts := []string{}
+		for _, name := range keys {
+			k := byName[name]
+			e, ok := existingMap[k]
+			if !ok {
+				continue
+			}
+			conflicts = append(conflicts, findConflicts(path+"."+name, e, generatedMap[k])...)
+		}
+		return conflicts
+	}
+
+	if reflect.DeepEqual(normalizeValue(existing), normalizeValue(generated)) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: manifest has %v, ducatify wants %v", path, describeValue(existing), describeValue(generated))}
+}
+
+// normalizeValue converts v so that values that are equal in yaml compare
+// equal with reflect.DeepEqual, whatever types the yaml package decoded
+// them to.
+func normalizeValue(v interface{}) interface{} {
+	if i, ok := integerValue(v); ok {
+		return i
+	}
+	switch val := v.(type) {
+	case []string:
+		items := []interface{}{}
+		for _, s := range val {
+			items = append(items, s)
+		}
+		return items
+	case []interface{}:
+		items := []interface{}{}
+		for _, item := range val {
+			items = append(items, normalizeValue(item))
+		}
+		return items
+	case map[interface{}]interface{}:
+		m := map[interface{}]interface{}{}
+		for k, item := range val {
+			m[k] = normalizeValue(item)
+		}
+		return m
+	}
+	return v
+}
+
+func describeValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func (t *Transformer) warnf(format string, args ...interface{}) {
+	if t.Warn != nil {
+		t.Warn(fmt.Sprintf(format, args...))
+	}
+}
+
+func mergeStringList(existing interface{}, additions []string) ([]string, error) {
+	merged := []string{}
+	seen := map[string]bool{}
+
+	if existing != nil {
+		var items []interface{}
+		switch e := existing.(type) {
+		case []interface{}:
+			items = e
+		case []string:
+			for _, s := range e {
+				items = append(items, s)
+			}
+		default:
+			return nil, fmt.Errorf("expected a list, got %T", existing)
+		}
+
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected list of strings, found %T", item)
+			}
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+			merged = append(merged, s)
+		}
+	}
+
+	for _, s := range additions {
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		merged = append(merged, s)
+	}
+
+	return merged, nil
+}
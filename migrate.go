@@ -0,0 +1,180 @@
+package ducatify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the version of the manifest layout that Transform
+// produces. Transform records it in properties.ducatify.schema_version so
+// that Migrate can later tell which migrations a manifest needs.
+const SchemaVersion = 2
+
+// Migration updates a manifest from schema version From to From+1.
+type Migration struct {
+	From        int
+	Description string
+
+	// Release is the first release version whose jobs need the migration,
+	// or empty if every release does. Migrate stops before a migration
+	// that the Transformer's ReleaseVersion does not need yet.
+	Release string
+
+	Apply func(t *Transformer, manifest *Manifest) error
+}
+
+// migrations lists every Migration in order of From.
+var migrations = []Migration{
+	{
+		From:        1,
+		Description: "add overlay_network and subnet_prefix_length to properties.ducati.daemon",
+		Apply: func(t *Transformer, manifest *Manifest) error {
+			daemon, err := ensureBlock(manifest.Properties, "ducati.daemon", nil)
+			if err != nil {
+				return err
+			}
+			if _, ok := daemon["overlay_network"]; !ok {
+				daemon["overlay_network"] = t.OverlayNetwork
+			}
+			if _, ok := daemon["subnet_prefix_length"]; !ok {
+				daemon["subnet_prefix_length"] = t.OverlaySubnetPrefixLength
+			}
+			return nil
+		},
+	},
+}
+
+// ManifestSchemaVersion returns the schema version recorded in a manifest.
+// A manifest without a record that has the ducati release was transformed
+// before versions were recorded and is version 1. Other manifests are
+// version 0, meaning they have not been transformed.
+func ManifestSchemaVersion(manifest *Manifest) (int, error) {
+	marker, err := lookupProperty(manifest.Properties, "ducatify.schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if marker == nil {
+		if manifest.hasRelease("ducati") {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	version, ok := integerValue(marker)
+	if !ok || version < 1 {
+		return 0, fmt.Errorf("expected properties.ducatify.schema_version to be a positive integer, got %v", marker)
+	}
+	return int(version), nil
+}
+
+// Migrate returns a copy of a transformed manifest with the migrations
+// from its schema version up to SchemaVersion applied, and the
+// descriptions of the migrations that ran. Like Transform, it leaves the
+// manifest itself untouched.
+func (t *Transformer) Migrate(manifest map[interface{}]interface{}) (map[interface{}]interface{}, []string, error) {
+	m, err := DecodeManifest(manifest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding manifest: %s", err)
+	}
+
+	migrated, applied, err := t.MigrateManifest(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrated.Map(), applied, nil
+}
+
+// MigrateManifest is Migrate for a decoded Manifest.
+func (t *Transformer) MigrateManifest(manifest *Manifest) (*Manifest, []string, error) {
+	version, err := ManifestSchemaVersion(manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version == 0 {
+		return nil, nil, fmt.Errorf("manifest has not been transformed by ducatify, nothing to migrate")
+	}
+	if version > SchemaVersion {
+		return nil, nil, fmt.Errorf("manifest has schema version %d, newer than %d which this ducatify supports", version, SchemaVersion)
+	}
+
+	migrated, err := manifest.Copy()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied := []string{}
+	for _, migration := range migrations {
+		if migration.From < version {
+			continue
+		}
+		if !t.releaseNeeds(migration.Release) {
+			break
+		}
+		err = migration.Apply(t, migrated)
+		if err != nil {
+			return nil, nil, fmt.Errorf("migrating from schema version %d: %s", migration.From, err)
+		}
+		version = migration.From + 1
+		applied = append(applied, migration.Description)
+		t.logf("migrated to schema version %d: %s", version, migration.Description)
+	}
+
+	err = setSchemaVersion(migrated, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrated, applied, nil
+}
+
+func setSchemaVersion(manifest *Manifest, version int) error {
+	if manifest.Properties == nil {
+		manifest.Properties = map[interface{}]interface{}{}
+	}
+	marker, err := ensureBlock(manifest.Properties, "ducatify", nil)
+	if err != nil {
+		return err
+	}
+	marker["schema_version"] = version
+	return nil
+}
+
+// releaseNeeds reports whether the Transformer's ReleaseVersion is at least
+// release. The "latest" release needs every migration.
+func (t *Transformer) releaseNeeds(release string) bool {
+	if release == "" || t.ReleaseVersion == "latest" {
+		return true
+	}
+	return compareVersions(t.ReleaseVersion, release) >= 0
+}
+
+// compareVersions compares dotted release versions such as "0.12" and
+// "0.9" numerically, part by part. Parts that are not numbers compare as
+// strings.
+func compareVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aPart, bPart := "0", "0"
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+
+		aNum, aErr := strconv.Atoi(aPart)
+		bNum, bErr := strconv.Atoi(bPart)
+		switch {
+		case aErr == nil && bErr == nil && aNum != bNum:
+			if aNum < bNum {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && aPart != bPart:
+			if aPart < bPart {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,162 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrate", func() {
+	var (
+		transformer *ducatify.Transformer
+		manifest    map[interface{}]interface{}
+	)
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{
+				map[interface{}]interface{}{"name": "ducati", "version": "latest"},
+			},
+			"properties": map[interface{}]interface{}{
+				"ducati": map[interface{}]interface{}{
+					"daemon": map[interface{}]interface{}{
+						"database": map[interface{}]interface{}{"host": "some-host"},
+					},
+				},
+			},
+		}
+	})
+
+	schemaVersion := func(manifest map[interface{}]interface{}) int {
+		m, err := ducatify.DecodeManifest(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		version, err := ducatify.ManifestSchemaVersion(m)
+		Expect(err).NotTo(HaveOccurred())
+		return version
+	}
+
+	Describe("ManifestSchemaVersion", func() {
+		It("is 0 for a manifest without ducati", func() {
+			Expect(schemaVersion(map[interface{}]interface{}{})).To(Equal(0))
+		})
+
+		It("is 1 for a manifest transformed before versions were recorded", func() {
+			Expect(schemaVersion(manifest)).To(Equal(1))
+		})
+
+		It("is the recorded version of a transformed manifest", func() {
+			manifest["properties"].(map[interface{}]interface{})["ducatify"] = map[interface{}]interface{}{
+				"schema_version": 7,
+			}
+			Expect(schemaVersion(manifest)).To(Equal(7))
+		})
+
+		It("accepts a record of any integer type", func() {
+			for _, marker := range []interface{}{int64(2), uint64(2)} {
+				manifest["properties"].(map[interface{}]interface{})["ducatify"] = map[interface{}]interface{}{
+					"schema_version": marker,
+				}
+				Expect(schemaVersion(manifest)).To(Equal(2))
+			}
+		})
+
+		It("rejects a malformed record", func() {
+			manifest["properties"].(map[interface{}]interface{})["ducatify"] = map[interface{}]interface{}{
+				"schema_version": "two",
+			}
+			m, err := ducatify.DecodeManifest(manifest)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = ducatify.ManifestSchemaVersion(m)
+			Expect(err).To(MatchError(ContainSubstring("schema_version to be a positive integer")))
+		})
+	})
+
+	It("records the current schema version when transforming", func() {
+		transformed, err := transformer.Transform(map[interface{}]interface{}{
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1"},
+			},
+			"properties": map[interface{}]interface{}{
+				"diego": map[interface{}]interface{}{
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schemaVersion(transformed)).To(Equal(ducatify.SchemaVersion))
+	})
+
+	It("applies the migrations a manifest needs and records the new version", func() {
+		migrated, applied, err := transformer.Migrate(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(ConsistOf(
+			"add overlay_network and subnet_prefix_length to properties.ducati.daemon",
+		))
+
+		daemon := migrated["properties"].(map[interface{}]interface{})["ducati"].(map[interface{}]interface{})["daemon"]
+		Expect(daemon).To(HaveKeyWithValue("overlay_network", "10.255.0.0/16"))
+		Expect(daemon).To(HaveKeyWithValue("subnet_prefix_length", 24))
+		Expect(daemon).To(HaveKeyWithValue("database", map[interface{}]interface{}{"host": "some-host"}))
+		Expect(schemaVersion(migrated)).To(Equal(ducatify.SchemaVersion))
+
+		By("leaving the input untouched")
+		Expect(manifest["properties"]).NotTo(HaveKey("ducatify"))
+	})
+
+	It("keeps settings the manifest already has", func() {
+		daemon := manifest["properties"].(map[interface{}]interface{})["ducati"].(map[interface{}]interface{})["daemon"].(map[interface{}]interface{})
+		daemon["overlay_network"] = "10.1.0.0/16"
+
+		migrated, _, err := transformer.Migrate(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		daemon = migrated["properties"].(map[interface{}]interface{})["ducati"].(map[interface{}]interface{})["daemon"].(map[interface{}]interface{})
+		Expect(daemon).To(HaveKeyWithValue("overlay_network", "10.1.0.0/16"))
+	})
+
+	It("does nothing for an up to date manifest", func() {
+		migrated, _, err := transformer.Migrate(manifest)
+		Expect(err).NotTo(HaveOccurred())
+
+		again, applied, err := transformer.Migrate(migrated)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(BeEmpty())
+		Expect(again).To(Equal(migrated))
+	})
+
+	It("finds a manifest read back from yaml up to date", func() {
+		var parsed map[interface{}]interface{}
+		Expect(candiedyaml.Unmarshal([]byte(`---
+releases:
+- name: ducati
+  version: latest
+properties:
+  ducatify:
+    schema_version: 2
+  ducati:
+    daemon:
+      overlay_network: 10.255.0.0/16
+      subnet_prefix_length: 24
+`), &parsed)).To(Succeed())
+		Expect(schemaVersion(parsed)).To(Equal(2))
+
+		_, applied, err := transformer.Migrate(parsed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(BeEmpty())
+	})
+
+	It("rejects a manifest that was never transformed", func() {
+		_, _, err := transformer.Migrate(map[interface{}]interface{}{})
+		Expect(err).To(MatchError(ContainSubstring("has not been transformed by ducatify")))
+	})
+
+	It("rejects a manifest from a newer ducatify", func() {
+		manifest["properties"].(map[interface{}]interface{})["ducatify"] = map[interface{}]interface{}{
+			"schema_version": ducatify.SchemaVersion + 1,
+		}
+		_, _, err := transformer.Migrate(manifest)
+		Expect(err).To(MatchError(ContainSubstring("newer than")))
+	})
+})
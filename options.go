@@ -0,0 +1,146 @@
+package ducatify
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"sort"
+)
+
+// Option changes a setting of the Transformer that New returns.
+type Option func(t *Transformer)
+
+// WithExternalDB makes the daemons use an existing postgres server at
+// host and port instead of a ducati_db job added to the manifest.
+func WithExternalDB(host string, port int) Option {
+	return func(t *Transformer) {
+		t.ExternalDBHost = host
+		t.DBPort = port
+	}
+}
+
+// WithDBCredentials sets the user that the daemons connect to the database
+// as.
+func WithDBCredentials(username, password string) Option {
+	return func(t *Transformer) {
+		t.DBUsername = username
+		t.DBPassword = password
+	}
+}
+
+// WithGardenPlugin sets the garden network plugin and, when any are given,
+// replaces its extra arguments. Without extra arguments the current ones,
+// such as the ducati adapter's --configFile, are kept.
+func WithGardenPlugin(plugin string, extraArgs ...string) Option {
+	return func(t *Transformer) {
+		t.GardenNetworkPlugin = plugin
+		if len(extraArgs) > 0 {
+			t.GardenNetworkPluginExtraArgs = extraArgs
+		}
+	}
+}
+
+// WithOnlySteps makes Transform run only the named steps.
+func WithOnlySteps(names ...string) Option {
+	return func(t *Transformer) {
+		t.OnlySteps = names
+	}
+}
+
+// WithSkipSteps makes Transform run every step but the named ones.
+func WithSkipSteps(names ...string) Option {
+	return func(t *Transformer) {
+		t.SkipSteps = names
+	}
+}
+
+// sslModes are the sslmode values that postgres clients accept.
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Check reports the first setting that would produce a broken manifest.
+// Transform calls it before looking at the manifest.
+func (t *Transformer) Check() error {
+	if _, err := t.backend(); err != nil {
+		return err
+	}
+	if t.DBName == "" {
+		return fmt.Errorf("db_name must not be empty")
+	}
+	if !containsString(sslModes, t.DBSSLMode) {
+		return fmt.Errorf("invalid db_ssl_mode %q, expected one of %v", t.DBSSLMode, sslModes)
+	}
+	if t.DBPort < 1 || t.DBPort > 65535 {
+		return fmt.Errorf("invalid db_port %d", t.DBPort)
+	}
+
+	err := t.checkConflictPolicies()
+	if err != nil {
+		return err
+	}
+
+	err = checkGardenSettings("garden", t.GardenNetworkPlugin, t.GardenDNSServers)
+	if err != nil {
+		return err
+	}
+	for job, override := range t.GardenOverrides {
+		err = checkGardenSettings("garden override for "+job, override.NetworkPlugin, override.DNSServers)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = t.checkStepSelection()
+	if err != nil {
+		return err
+	}
+
+	err = t.checkServiceDiscovery()
+	if err != nil {
+		return fmt.Errorf("checking service discovery: %s", err)
+	}
+
+	err = t.CNI.check()
+	if err != nil {
+		return fmt.Errorf("checking cni config: %s", err)
+	}
+	return nil
+}
+
+// checkConflictPolicies rejects policies that Transform would only trip
+// over once it finds a conflict in the manifest.
+func (t *Transformer) checkConflictPolicies() error {
+	switch t.GardenNetworkPluginConflict {
+	case ConflictWarn, ConflictFail:
+	default:
+		return fmt.Errorf("invalid garden_network_plugin_conflict %q, expected warn or fail", t.GardenNetworkPluginConflict)
+	}
+
+	trees := []string{}
+	for tree := range t.PropertyConflicts {
+		trees = append(trees, tree)
+	}
+	sort.Strings(trees)
+	for _, tree := range trees {
+		if !containsString(PropertyTrees, tree) {
+			return fmt.Errorf("unknown property tree %q in property_conflicts, expected one of %v", tree, PropertyTrees)
+		}
+		switch policy := t.PropertyConflicts[tree]; policy {
+		case ConflictOverwrite, ConflictMerge, ConflictFail:
+		default:
+			return fmt.Errorf("invalid property_conflicts policy %q for %s, expected overwrite, merge or fail", policy, tree)
+		}
+	}
+	return nil
+}
+
+func checkGardenSettings(context, plugin string, dnsServers []string) error {
+	if plugin != "" && !path.IsAbs(plugin) {
+		return fmt.Errorf("%s: network plugin %q must be an absolute path", context, plugin)
+	}
+	for _, server := range dnsServers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("%s: dns server %q is not an IP address", context, server)
+		}
+	}
+	return nil
+}
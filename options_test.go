@@ -0,0 +1,127 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Options", func() {
+	It("applies options on top of the defaults, in order", func() {
+		transformer := ducatify.New(
+			ducatify.WithDBCredentials("some-user", "some-password"),
+			ducatify.WithGardenPlugin("/some/plugin", "--some-arg"),
+			ducatify.WithGardenPlugin("/other/plugin"),
+		)
+
+		Expect(transformer.DBUsername).To(Equal("some-user"))
+		Expect(transformer.DBPassword).To(Equal("some-password"))
+		Expect(transformer.GardenNetworkPlugin).To(Equal("/other/plugin"))
+		Expect(transformer.GardenNetworkPluginExtraArgs).To(Equal([]string{"--some-arg"}))
+		Expect(transformer.DBName).To(Equal("ducati"))
+	})
+
+	It("keeps the default plugin arguments when a plugin is given without any", func() {
+		defaults := ducatify.New()
+		transformer := ducatify.New(ducatify.WithGardenPlugin("/other/plugin"))
+
+		Expect(transformer.GardenNetworkPluginExtraArgs).To(Equal(defaults.GardenNetworkPluginExtraArgs))
+		Expect(transformer.GardenNetworkPluginExtraArgs).To(ContainElement("--configFile=/var/vcap/jobs/ducati/config/adapter.json"))
+	})
+
+	Describe("an external database", func() {
+		var manifest map[interface{}]interface{}
+
+		BeforeEach(func() {
+			manifest = map[interface{}]interface{}{
+				"jobs": []interface{}{
+					map[interface{}]interface{}{"name": "cc_bridge_z1", "templates": []interface{}{}},
+					map[interface{}]interface{}{"name": "cell_z1", "templates": []interface{}{}},
+				},
+				"properties": map[interface{}]interface{}{
+					"garden": map[interface{}]interface{}{},
+					"diego": map[interface{}]interface{}{
+						"nsync":         map[interface{}]interface{}{},
+						"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+					},
+				},
+			}
+		})
+
+		It("points the daemons at it instead of adding a ducati_db job", func() {
+			transformer := ducatify.New(ducatify.WithExternalDB("db.example.com", 6543))
+
+			transformed, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, job := range transformed["jobs"].([]interface{}) {
+				Expect(job).NotTo(HaveKeyWithValue("name", "ducati_db"))
+			}
+			props := transformed["properties"].(map[interface{}]interface{})
+			ducati := props["ducati"].(map[interface{}]interface{})
+			Expect(ducati).NotTo(HaveKey("database"))
+			for _, tree := range []string{"ducati", "connet"} {
+				daemon := props[tree].(map[interface{}]interface{})["daemon"].(map[interface{}]interface{})
+				Expect(daemon["database"]).To(HaveKeyWithValue("host", "db.example.com"))
+				Expect(daemon["database"]).To(HaveKeyWithValue("port", 6543))
+			}
+		})
+	})
+
+	Describe("Check", func() {
+		It("accepts the defaults", func() {
+			Expect(ducatify.New().Check()).To(Succeed())
+		})
+
+		itRejects := func(description string, opt ducatify.Option, message string) {
+			It("rejects "+description, func() {
+				transformer := ducatify.New(opt)
+				Expect(transformer.Check()).To(MatchError(ContainSubstring(message)))
+
+				_, err := transformer.Transform(map[interface{}]interface{}{}, map[interface{}]interface{}{}, "some.system.domain")
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			})
+		}
+
+		itRejects("an empty db name", func(t *ducatify.Transformer) {
+			t.DBName = ""
+		}, "db_name must not be empty")
+
+		itRejects("an invalid ssl mode", func(t *ducatify.Transformer) {
+			t.DBSSLMode = "sometimes"
+		}, `invalid db_ssl_mode "sometimes"`)
+
+		itRejects("an invalid database port", ducatify.WithExternalDB("db.example.com", 0),
+			"invalid db_port 0")
+
+		itRejects("a relative plugin path", ducatify.WithGardenPlugin("bin/guardian-cni-adapter"),
+			`garden: network plugin "bin/guardian-cni-adapter" must be an absolute path`)
+
+		itRejects("a malformed dns server", func(t *ducatify.Transformer) {
+			t.GardenDNSServers = []string{"8.8.8.8", "8.8.8"}
+		}, `garden: dns server "8.8.8" is not an IP address`)
+
+		itRejects("a bad garden override", func(t *ducatify.Transformer) {
+			t.GardenOverrides = map[string]ducatify.GardenOverride{
+				"cell_z1": {NetworkPlugin: "plugin"},
+			}
+		}, `garden override for cell_z1: network plugin "plugin" must be an absolute path`)
+
+		itRejects("an unknown garden plugin conflict policy", func(t *ducatify.Transformer) {
+			t.GardenNetworkPluginConflict = "bogus"
+		}, `invalid garden_network_plugin_conflict "bogus", expected warn or fail`)
+
+		itRejects("an unknown property conflict policy", func(t *ducatify.Transformer) {
+			t.PropertyConflicts = map[string]ducatify.ConflictPolicy{"ducati": "bogus"}
+		}, `invalid property_conflicts policy "bogus" for ducati`)
+
+		itRejects("a conflict policy for a property tree ducatify does not write", func(t *ducatify.Transformer) {
+			t.PropertyConflicts = map[string]ducatify.ConflictPolicy{"garden": ducatify.ConflictMerge}
+		}, `unknown property tree "garden" in property_conflicts`)
+
+		itRejects("an unknown backend", func(t *ducatify.Transformer) {
+			t.Backend = "nope"
+		}, `unknown backend "nope"`)
+	})
+})
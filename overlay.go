@@ -0,0 +1,52 @@
+package ducatify
+
+import (
+	"fmt"
+	"net"
+)
+
+// checkOverlayNetwork makes sure the overlay network ducati hands container
+// subnets out of does not collide with any subnet in the manifest, and that
+// it is large enough to give every cell its own subnet.
+func (t *Transformer) checkOverlayNetwork(manifest *Manifest) error {
+	_, overlay, err := net.ParseCIDR(t.OverlayNetwork)
+	if err != nil {
+		return fmt.Errorf("parsing overlay network: %s", err)
+	}
+	overlayPrefix, bits := overlay.Mask.Size()
+	if t.OverlaySubnetPrefixLength < overlayPrefix || t.OverlaySubnetPrefixLength > bits-2 {
+		return fmt.Errorf("cell subnet prefix length /%d must be between the overlay's /%d and /%d",
+			t.OverlaySubnetPrefixLength, overlayPrefix, bits-2)
+	}
+
+	for _, network := range manifest.Networks {
+		for _, subnet := range network.Subnets {
+			if subnet.Range == "" {
+				continue
+			}
+			_, subnetRange, err := net.ParseCIDR(subnet.Range)
+			if err != nil {
+				return fmt.Errorf("parsing range of network %v: %s", network.Name, err)
+			}
+			if subnetRange.Contains(overlay.IP) || overlay.Contains(subnetRange.IP) {
+				return fmt.Errorf("overlay network %s overlaps range %s of network %v", overlay, subnetRange, network.Name)
+			}
+		}
+	}
+
+	ducatiCells, _, err := t.ducatiCells(manifest)
+	if err != nil {
+		return err
+	}
+
+	cells := 0
+	for _, job := range ducatiCells {
+		cells += job.Instances
+	}
+
+	if subnetBits := uint(t.OverlaySubnetPrefixLength - overlayPrefix); subnetBits < 31 && cells > 1<<subnetBits {
+		return fmt.Errorf("overlay network %s only fits %d /%d cell subnets but the manifest has %d cells",
+			overlay, 1<<subnetBits, t.OverlaySubnetPrefixLength, cells)
+	}
+	return nil
+}
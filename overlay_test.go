@@ -0,0 +1,96 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checking the overlay network", func() {
+	var (
+		manifest    map[interface{}]interface{}
+		transformer *ducatify.Transformer
+	)
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "instances": 1, "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z1", "instances": 3, "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "colocated_z2", "instances": 2, "templates": []interface{}{}},
+			},
+			"networks": []interface{}{
+				map[interface{}]interface{}{
+					"name": "diego1",
+					"subnets": []interface{}{
+						map[interface{}]interface{}{"range": "10.244.16.0/24"},
+					},
+				},
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	transform := func() error {
+		transformed, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		if err == nil {
+			manifest = transformed
+		}
+		return err
+	}
+
+	It("accepts an overlay that is clear of the manifest's subnets and fits every cell", func() {
+		Expect(transform()).To(Succeed())
+	})
+
+	It("fails when the overlay overlaps a subnet", func() {
+		transformer.OverlayNetwork = "10.244.0.0/16"
+
+		Expect(transform()).To(MatchError(
+			"checking overlay network: overlay network 10.244.0.0/16 overlaps range 10.244.16.0/24 of network diego1"))
+	})
+
+	It("fails when a subnet contains the overlay", func() {
+		transformer.OverlayNetwork = "10.244.16.128/25"
+		transformer.OverlaySubnetPrefixLength = 28
+
+		Expect(transform()).To(MatchError(ContainSubstring("overlaps range 10.244.16.0/24 of network diego1")))
+	})
+
+	It("fails when the overlay cannot fit a subnet for every cell", func() {
+		transformer.OverlayNetwork = "10.255.0.0/23"
+
+		Expect(transform()).To(MatchError(
+			"checking overlay network: overlay network 10.255.0.0/23 only fits 2 /24 cell subnets but the manifest has 5 cells"))
+	})
+
+	It("counts instances of any integer type", func() {
+		jobs := manifest["jobs"].([]interface{})
+		jobs[1].(map[interface{}]interface{})["instances"] = int64(3)
+		jobs[2].(map[interface{}]interface{})["instances"] = uint64(2)
+		transformer.OverlayNetwork = "10.255.0.0/23"
+
+		Expect(transform()).To(MatchError(ContainSubstring("only fits 2 /24 cell subnets but the manifest has 5 cells")))
+	})
+
+	It("fails for a cell subnet larger than the overlay", func() {
+		transformer.OverlaySubnetPrefixLength = 12
+
+		Expect(transform()).To(MatchError(ContainSubstring("cell subnet prefix length /12 must be between the overlay's /16 and /30")))
+	})
+
+	It("fails for an unparseable overlay", func() {
+		transformer.OverlayNetwork = "not-a-cidr"
+
+		Expect(transform()).To(MatchError(ContainSubstring("parsing overlay network")))
+	})
+})
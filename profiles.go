@@ -0,0 +1,77 @@
+package ducatify
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Profile adjusts a Transformer's settings for a particular kind of
+// environment, e.g. a specific IaaS.
+type Profile func(t *Transformer)
+
+var (
+	profilesLock sync.RWMutex
+	profiles     = map[string]Profile{
+		"bosh-lite": func(t *Transformer) {
+			t.DBPersistentDisk = 256
+			t.DBResourcePool = "database_z1"
+			t.DBNetwork = "diego1"
+			t.GardenDNSServers = []string{"192.168.255.254"}
+		},
+		"aws": func(t *Transformer) {
+			t.DBPersistentDisk = 10240
+			t.DBResourcePool = "database_z1"
+			t.DBNetwork = "diego1"
+			// the Amazon-provided resolver, reachable from any VPC
+			t.GardenDNSServers = []string{"169.254.169.253"}
+		},
+		"vsphere": func(t *Transformer) {
+			t.DBPersistentDisk = 10240
+			t.DBResourcePool = "database_z1"
+			t.DBNetwork = "diego1"
+			// there is no resolver common to vSphere installations, so
+			// containers keep whatever the cell uses unless configured
+			t.GardenDNSServers = nil
+		},
+	}
+)
+
+// RegisterProfile makes a profile available to ApplyProfile, replacing any
+// existing profile with the same name.
+func RegisterProfile(name string, profile Profile) {
+	profilesLock.Lock()
+	defer profilesLock.Unlock()
+	profiles[name] = profile
+}
+
+// ProfileNames returns the names of all registered profiles in sorted order.
+func ProfileNames() []string {
+	profilesLock.RLock()
+	defer profilesLock.RUnlock()
+
+	names := []string{}
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProfile returns the profile registered under name.
+func LookupProfile(name string) (Profile, bool) {
+	profilesLock.RLock()
+	defer profilesLock.RUnlock()
+
+	profile, ok := profiles[name]
+	return profile, ok
+}
+
+func (t *Transformer) ApplyProfile(name string) error {
+	profile, ok := LookupProfile(name)
+	if !ok {
+		return fmt.Errorf("unknown profile %q, expected one of %v", name, ProfileNames())
+	}
+	profile(t)
+	return nil
+}
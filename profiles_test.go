@@ -0,0 +1,47 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Profiles", func() {
+	var transformer *ducatify.Transformer
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+	})
+
+	It("ships profiles for bosh-lite, aws and vsphere", func() {
+		Expect(ducatify.ProfileNames()).To(ContainElement("bosh-lite"))
+		Expect(ducatify.ProfileNames()).To(ContainElement("aws"))
+		Expect(ducatify.ProfileNames()).To(ContainElement("vsphere"))
+	})
+
+	It("keeps New's defaults in the bosh-lite profile", func() {
+		Expect(transformer.ApplyProfile("bosh-lite")).To(Succeed())
+		Expect(transformer).To(Equal(ducatify.New()))
+	})
+
+	It("tunes the transformer for the selected IaaS", func() {
+		Expect(transformer.ApplyProfile("aws")).To(Succeed())
+		Expect(transformer.GardenDNSServers).To(Equal([]string{"169.254.169.253"}))
+		Expect(transformer.DBPersistentDisk).To(Equal(10240))
+	})
+
+	It("applies registered profiles", func() {
+		ducatify.RegisterProfile("some-team", func(t *ducatify.Transformer) {
+			t.DBNetwork = "some-network"
+		})
+
+		Expect(transformer.ApplyProfile("some-team")).To(Succeed())
+		Expect(transformer.DBNetwork).To(Equal("some-network"))
+	})
+
+	It("returns an error for an unknown profile", func() {
+		err := transformer.ApplyProfile("nope")
+		Expect(err).To(MatchError(ContainSubstring(`unknown profile "nope"`)))
+	})
+})
@@ -0,0 +1,97 @@
+package ducatify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/candiedyaml"
+)
+
+// Version and Commit identify the ducatify build. Release builds set them
+// with -ldflags "-X github.com/cloudfoundry-incubator/ducatify.Version=...".
+var (
+	Version = "dev"
+	Commit  = "unknown"
+)
+
+// Provenance identifies the inputs of a transformation. When the
+// Transformer has one, Transform records it in
+// properties.ducatify.provenance together with Version and the redacted
+// settings.
+type Provenance struct {
+	// InputSHA256 and CredsSHA256 are the hex-encoded SHA-256 sums of the
+	// diego manifest and the cf creds files.
+	InputSHA256 string
+	CredsSHA256 string
+}
+
+const redacted = "<redacted>"
+
+// RedactedSettings returns the Transformer's settings keyed by their yaml
+// names, as in a config file, with every password replaced.
+func (t *Transformer) RedactedSettings() (map[interface{}]interface{}, error) {
+	settingsBytes, err := candiedyaml.Marshal(t)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling settings: %s", err)
+	}
+
+	var settings map[interface{}]interface{}
+	err = candiedyaml.Unmarshal(settingsBytes, &settings)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling settings: %s", err)
+	}
+	redact(settings)
+	return settings, nil
+}
+
+// redact replaces the value of every key that names a password or other
+// secret, at any depth.
+func redact(val interface{}) {
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		for key, el := range v {
+			if name, ok := key.(string); ok && isSecret(name) && el != nil && el != "" {
+				v[key] = redacted
+				continue
+			}
+			redact(el)
+		}
+	case []interface{}:
+		for _, el := range v {
+			redact(el)
+		}
+	}
+}
+
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"password", "secret", "token"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Transformer) recordProvenance(manifest *Manifest) error {
+	settings, err := t.RedactedSettings()
+	if err != nil {
+		return err
+	}
+
+	if manifest.Properties == nil {
+		manifest.Properties = map[interface{}]interface{}{}
+	}
+	block, err := ensureBlock(manifest.Properties, "ducatify", nil)
+	if err != nil {
+		return err
+	}
+	block["provenance"] = map[interface{}]interface{}{
+		"version":      Version,
+		"commit":       Commit,
+		"input_sha256": t.Provenance.InputSHA256,
+		"creds_sha256": t.Provenance.CredsSHA256,
+		"settings":     settings,
+	}
+	return nil
+}
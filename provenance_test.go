@@ -0,0 +1,84 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Provenance", func() {
+	var (
+		transformer *ducatify.Transformer
+		manifest    map[interface{}]interface{}
+	)
+
+	BeforeEach(func() {
+		transformer = ducatify.New(ducatify.WithDBCredentials("some-user", "some-secret-password"))
+		manifest = map[interface{}]interface{}{
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1"},
+			},
+			"properties": map[interface{}]interface{}{
+				"diego": map[interface{}]interface{}{
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	ducatifyBlock := func(manifest map[interface{}]interface{}) map[interface{}]interface{} {
+		return manifest["properties"].(map[interface{}]interface{})["ducatify"].(map[interface{}]interface{})
+	}
+
+	It("lists the settings by their yaml names with passwords redacted", func() {
+		settings, err := transformer.RedactedSettings()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(settings).To(HaveKeyWithValue("db_username", "some-user"))
+		Expect(settings).To(HaveKeyWithValue("db_password", "<redacted>"))
+		Expect(settings).To(HaveKeyWithValue("backend", "ducati"))
+		Expect(settings).NotTo(HaveKey("warn"))
+		Expect(transformer.DBPassword).To(Equal("some-secret-password"))
+	})
+
+	It("redacts secrets nested in the settings", func() {
+		transformer.CNI = ducatify.CNIConfig{
+			CNIVersion: "0.3.1",
+			Name:       "some-net",
+			Plugins: []ducatify.CNIPlugin{
+				{Type: "some-plugin", Args: map[string]interface{}{"auth_token": "some-token"}},
+			},
+		}
+
+		settings, err := transformer.RedactedSettings()
+		Expect(err).NotTo(HaveOccurred())
+		plugin := settings["cni"].(map[interface{}]interface{})["plugins"].([]interface{})[0]
+		Expect(plugin.(map[interface{}]interface{})["args"]).To(HaveKeyWithValue("auth_token", "<redacted>"))
+	})
+
+	It("records the version, input hashes and settings when given a Provenance", func() {
+		transformer.Provenance = &ducatify.Provenance{
+			InputSHA256: "some-input-sum",
+			CredsSHA256: "some-creds-sum",
+		}
+
+		transformed, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+
+		provenance := ducatifyBlock(transformed)["provenance"].(map[interface{}]interface{})
+		Expect(provenance).To(HaveKeyWithValue("version", ducatify.Version))
+		Expect(provenance).To(HaveKeyWithValue("commit", ducatify.Commit))
+		Expect(provenance).To(HaveKeyWithValue("input_sha256", "some-input-sum"))
+		Expect(provenance).To(HaveKeyWithValue("creds_sha256", "some-creds-sum"))
+		Expect(provenance["settings"]).To(HaveKeyWithValue("db_password", "<redacted>"))
+	})
+
+	It("records nothing but the schema version without a Provenance", func() {
+		transformed, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ducatifyBlock(transformed)).To(Equal(map[interface{}]interface{}{
+			"schema_version": ducatify.SchemaVersion,
+		}))
+	})
+})
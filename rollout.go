@@ -0,0 +1,110 @@
+package ducatify
+
+import "fmt"
+
+// Stage is one deployable manifest in a phased rollout.
+type Stage struct {
+	Name        string
+	Description string
+	Manifest    map[interface{}]interface{}
+}
+
+// Rollout splits the transformation into stages that can be deployed one
+// after another: first everything the backend adds outside the cells, then
+// the backend on one cell job at a time, and finally the settings that
+// affect every app. The last stage is the same as the result of Transform.
+// The input manifest is left untouched.
+func (t *Transformer) Rollout(
+	manifest map[interface{}]interface{},
+	acceptanceJobConfig map[interface{}]interface{},
+	systemDomain string,
+) ([]Stage, error) {
+	if len(t.OnlySteps) > 0 || len(t.SkipSteps) > 0 {
+		return nil, fmt.Errorf("a rollout runs every step, so it cannot be combined with only_steps or skip_steps")
+	}
+
+	final, err := t.Transform(manifest, acceptanceJobConfig, systemDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := t.CellAssignments(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Transform has already reported any problems and traced its steps,
+	// so don't repeat them for every stage
+	quiet := *t
+	quiet.Warn = nil
+	quiet.Log = nil
+	quiet.Hooks = StepHooks{}
+
+	current, err := DecodeManifest(deepCopy(manifest).(map[interface{}]interface{}))
+	if err != nil {
+		return nil, fmt.Errorf("decoding manifest: %s", err)
+	}
+	in := stepInput{
+		manifest:            current,
+		acceptanceJobConfig: acceptanceJobConfig,
+		systemDomain:        systemDomain,
+	}
+
+	backend, err := quiet.backend()
+	if err != nil {
+		return nil, err
+	}
+	steps, err := quiet.steps()
+	if err != nil {
+		return nil, err
+	}
+
+	// the cells, and the settings that affect every app, come later
+	setupSteps := []string{}
+	for _, s := range steps {
+		switch s.name {
+		case "cells", "garden", "nsync", "acceptance-job", "acceptance-props":
+		default:
+			setupSteps = append(setupSteps, s.name)
+		}
+	}
+	err = quiet.runSteps(steps, in, setupSteps...)
+	if err != nil {
+		return nil, err
+	}
+	setupName, setupDescription := backend.SetupStage()
+	stages := []Stage{{
+		Name:        setupName,
+		Description: setupDescription,
+		Manifest:    deepCopy(current.Map()).(map[interface{}]interface{}),
+	}}
+
+	enabled := []string{}
+	for _, assignment := range assignments {
+		if !assignment.Ducati {
+			continue
+		}
+		enabled = append(enabled, assignment.Job)
+
+		stageTransformer := quiet
+		stageTransformer.Cells = CellFilter{IncludeJobs: append([]string{}, enabled...)}
+		stageTransformer.scopeGardenToCells = true
+		err = stageTransformer.runSteps(steps, in, "cells", "garden")
+		if err != nil {
+			return nil, fmt.Errorf("enabling %s on %s: %s", t.Backend, assignment.Job, err)
+		}
+
+		stages = append(stages, Stage{
+			Name:        "cells-" + assignment.Job,
+			Description: fmt.Sprintf("enable %s on %s, with garden properties scoped to the job", t.Backend, assignment.Job),
+			Manifest:    deepCopy(current.Map()).(map[interface{}]interface{}),
+		})
+	}
+
+	stages = append(stages, Stage{
+		Name:        "finish",
+		Description: "move the garden properties to the global block, set the nsync network_id and add any acceptance errand",
+		Manifest:    final,
+	})
+	return stages, nil
+}
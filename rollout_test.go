@@ -0,0 +1,110 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rollout", func() {
+	var (
+		manifest            map[interface{}]interface{}
+		acceptanceJobConfig map[interface{}]interface{}
+		transformer         *ducatify.Transformer
+	)
+
+	ducatiTemplate := map[interface{}]interface{}{"name": "ducati", "release": "ducati"}
+
+	jobNamed := func(manifest map[interface{}]interface{}, name string) map[interface{}]interface{} {
+		for _, job := range manifest["jobs"].([]interface{}) {
+			if job.(map[interface{}]interface{})["name"] == name {
+				return job.(map[interface{}]interface{})
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		acceptanceJobConfig = map[interface{}]interface{}{"api": "api.some.system.domain"}
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cc_bridge_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z2", "templates": []interface{}{}},
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	It("produces a stage for the database and connet, one per cell job, and a final stage", func() {
+		stages, err := transformer.Rollout(manifest, acceptanceJobConfig, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+
+		names := []string{}
+		for _, stage := range stages {
+			names = append(names, stage.Name)
+			Expect(stage.Description).NotTo(BeEmpty())
+		}
+		Expect(names).To(Equal([]string{"ducati-db-and-connet", "cells-cell_z1", "cells-cell_z2", "finish"}))
+	})
+
+	It("adds ducati_db and connet without touching the cells in the first stage", func() {
+		stages, err := transformer.Rollout(manifest, acceptanceJobConfig, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+
+		first := stages[0].Manifest
+		Expect(jobNamed(first, "ducati_db")).NotTo(BeNil())
+		Expect(jobNamed(first, "cc_bridge_z1")["templates"]).To(ContainElement(
+			map[interface{}]interface{}{"name": "connet", "release": "ducati"}))
+		Expect(jobNamed(first, "cell_z1")["templates"]).To(BeEmpty())
+		Expect(first["properties"]).To(HaveKey("connet"))
+		Expect(first["properties"]).To(HaveKeyWithValue("garden", map[interface{}]interface{}{}))
+	})
+
+	It("converts one more cell job in each cell stage, scoping garden properties to it", func() {
+		stages, err := transformer.Rollout(manifest, acceptanceJobConfig, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+
+		second := stages[1].Manifest
+		Expect(jobNamed(second, "cell_z1")["templates"]).To(ConsistOf(ducatiTemplate))
+		Expect(jobNamed(second, "cell_z1")["properties"]).To(HaveKey("garden"))
+		Expect(jobNamed(second, "cell_z2")["templates"]).To(BeEmpty())
+		Expect(second["properties"]).To(HaveKeyWithValue("garden", map[interface{}]interface{}{}))
+
+		third := stages[2].Manifest
+		Expect(jobNamed(third, "cell_z1")["templates"]).To(ConsistOf(ducatiTemplate))
+		Expect(jobNamed(third, "cell_z2")["templates"]).To(ConsistOf(ducatiTemplate))
+		Expect(third["properties"].(map[interface{}]interface{})["diego"].(map[interface{}]interface{})["nsync"]).NotTo(HaveKey("network_id"))
+	})
+
+	It("ends with the fully transformed manifest and leaves the input alone", func() {
+		stages, err := transformer.Rollout(manifest, acceptanceJobConfig, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(jobNamed(manifest, "ducati_db")).To(BeNil())
+		Expect(manifest["releases"]).To(BeEmpty())
+
+		transformed, err := transformer.Transform(manifest, acceptanceJobConfig, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stages[len(stages)-1].Manifest).To(Equal(transformed))
+	})
+
+	It("only converts the cells selected by the cell filter", func() {
+		transformer.Cells.ExcludeJobs = []string{"cell_z2"}
+
+		stages, err := transformer.Rollout(manifest, acceptanceJobConfig, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stages).To(HaveLen(3))
+		Expect(stages[1].Name).To(Equal("cells-cell_z1"))
+	})
+})
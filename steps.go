@@ -0,0 +1,250 @@
+package ducatify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stepInput carries everything a transformation step may need besides the
+// Transformer's own settings.
+type stepInput struct {
+	manifest            *Manifest
+	acceptanceJobConfig map[interface{}]interface{}
+	systemDomain        string
+}
+
+type step struct {
+	name    string
+	context string
+	run     func(t *Transformer, in stepInput) error
+
+	// requires names the steps whose changes this one builds on
+	requires []string
+
+	// applied reports whether the manifest already has the step's changes,
+	// so that steps requiring it can run without it. Nil means never.
+	applied func(t *Transformer, m *Manifest) bool
+}
+
+// steps lists every transformation step for the Transformer's backend in
+// the order Transform runs them.
+func (t *Transformer) steps() ([]step, error) {
+	backend, err := t.backend()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := []step{
+		{
+			name:    "releases",
+			context: "updating releases",
+			run: func(t *Transformer, in stepInput) error {
+				return t.updateReleases(in.manifest, backend)
+			},
+			applied: func(t *Transformer, m *Manifest) bool {
+				for _, release := range backend.Releases(t) {
+					if !m.hasRelease(release.Name) {
+						return false
+					}
+				}
+				return true
+			},
+		},
+		{
+			name:     "db-job",
+			context:  "adding " + t.Backend + " jobs",
+			requires: []string{"releases"},
+			run: func(t *Transformer, in stepInput) error {
+				return backend.AddJobs(t, in.manifest)
+			},
+		},
+		{
+			name:     "cc-bridge",
+			context:  "adding " + t.Backend + " templates to cc_bridge",
+			requires: []string{"releases"},
+			run: func(t *Transformer, in stepInput) error {
+				return backend.ModifyCCBridge(t, in.manifest, in.systemDomain)
+			},
+		},
+		{
+			name:     "cells",
+			context:  "adding " + t.Backend + " templates to cells",
+			requires: []string{"releases", "garden"},
+			run: func(t *Transformer, in stepInput) error {
+				err := t.modifyCellJob(in.manifest, "cell_z", backend)
+				if err != nil {
+					return err
+				}
+				return t.modifyCellJob(in.manifest, "colocated_z", backend)
+			},
+			applied: func(t *Transformer, m *Manifest) bool {
+				for _, job := range m.Jobs {
+					if !strings.HasPrefix(job.Name, "cell_z") && !strings.HasPrefix(job.Name, "colocated_z") {
+						continue
+					}
+					for _, template := range backend.CellTemplates(t, job.Name) {
+						if job.HasTemplate(template.Name) {
+							return true
+						}
+					}
+				}
+				return false
+			},
+		},
+		{
+			name:     "garden",
+			context:  "adding garden properties",
+			requires: []string{"cells"},
+			run: func(t *Transformer, in stepInput) error {
+				return t.addGardenProperties(in.manifest)
+			},
+			applied: func(t *Transformer, m *Manifest) bool {
+				if plugin, _ := lookupProperty(m.Properties, "garden.network_plugin"); plugin == t.GardenNetworkPlugin {
+					return true
+				}
+				for _, job := range m.Jobs {
+					if plugin, _ := lookupProperty(job.Properties, "garden.network_plugin"); plugin == t.GardenNetworkPlugin {
+						return true
+					}
+				}
+				return false
+			},
+		},
+		{
+			name:     "nsync",
+			context:  "adding nsync properties",
+			requires: []string{"cells"},
+			run: func(t *Transformer, in stepInput) error {
+				return t.addNsyncProperties(in.manifest)
+			},
+		},
+	}
+
+	for _, tree := range backend.PropertyTrees(t) {
+		tree := tree
+		steps = append(steps, step{
+			name:    tree.Name + "-props",
+			context: "adding " + tree.Name + " properties",
+			run: func(t *Transformer, in stepInput) error {
+				return t.addPropertyTree(in.manifest, tree)
+			},
+		})
+	}
+
+	errand, errandTree := backend.AcceptanceErrand(t)
+	if errand != nil {
+		steps = append(steps,
+			step{
+				name:     "acceptance-job",
+				context:  "adding acceptance job",
+				requires: []string{"releases", "acceptance-props"},
+				run: func(t *Transformer, in stepInput) error {
+					return t.addAcceptanceJob(in.manifest, errand)
+				},
+			},
+			step{
+				name:    "acceptance-props",
+				context: "adding acceptance job properties",
+				run: func(t *Transformer, in stepInput) error {
+					return t.addPropertyTree(in.manifest, PropertyTree{Name: errandTree, Value: in.acceptanceJobConfig})
+				},
+				applied: func(t *Transformer, m *Manifest) bool {
+					tree, _ := lookupProperty(m.Properties, errandTree)
+					return tree != nil
+				},
+			},
+		)
+	}
+	return steps, nil
+}
+
+// runSteps runs the named steps, in the order of steps, or all of them when
+// no names are given.
+func (t *Transformer) runSteps(steps []step, in stepInput, names ...string) error {
+	selected := map[string]bool{}
+	for _, name := range names {
+		selected[name] = true
+	}
+
+	for _, s := range steps {
+		if len(names) > 0 && !selected[s.name] {
+			continue
+		}
+		err := t.runStep(s, in)
+		if err != nil {
+			return fmt.Errorf("%s: %s", s.context, err)
+		}
+	}
+	return nil
+}
+
+// StepNames returns the names of the Transformer's steps for its backend,
+// in the order Transform runs them. OnlySteps and SkipSteps take these
+// names.
+func (t *Transformer) StepNames() ([]string, error) {
+	steps, err := t.steps()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, s := range steps {
+		names = append(names, s.name)
+	}
+	return names, nil
+}
+
+// checkStepSelection makes sure OnlySteps and SkipSteps name known steps
+// and don't both select steps.
+func (t *Transformer) checkStepSelection() error {
+	if len(t.OnlySteps) > 0 && len(t.SkipSteps) > 0 {
+		return fmt.Errorf("only_steps and skip_steps cannot be combined")
+	}
+	names, err := t.StepNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range append(append([]string{}, t.OnlySteps...), t.SkipSteps...) {
+		if !containsString(names, name) {
+			return fmt.Errorf("unknown step %q, expected one of %v", name, names)
+		}
+	}
+	return nil
+}
+
+// selectSteps returns the steps selected by OnlySteps and SkipSteps. A
+// selected step's requirements must be selected as well, or already be
+// applied to the manifest.
+func (t *Transformer) selectSteps(steps []step, manifest *Manifest) ([]step, error) {
+	if len(t.OnlySteps) == 0 && len(t.SkipSteps) == 0 {
+		return steps, nil
+	}
+
+	selected := map[string]bool{}
+	byName := map[string]step{}
+	for _, s := range steps {
+		byName[s.name] = s
+		if len(t.OnlySteps) > 0 {
+			selected[s.name] = containsString(t.OnlySteps, s.name)
+		} else {
+			selected[s.name] = !containsString(t.SkipSteps, s.name)
+		}
+	}
+
+	result := []step{}
+	for _, s := range steps {
+		if !selected[s.name] {
+			continue
+		}
+		for _, name := range s.requires {
+			required, ok := byName[name]
+			if !ok || selected[name] {
+				continue
+			}
+			if required.applied == nil || !required.applied(t, manifest) {
+				return nil, fmt.Errorf("step %s needs step %s, which is not selected and has not been applied to the manifest", s.name, name)
+			}
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Selecting steps", func() {
+	var (
+		manifest    map[interface{}]interface{}
+		transformer *ducatify.Transformer
+	)
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cc_bridge_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z1", "templates": []interface{}{}},
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+	})
+
+	transform := func() (map[interface{}]interface{}, error) {
+		return transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+	}
+
+	It("names the steps of the backend in order", func() {
+		Expect(transformer.StepNames()).To(Equal([]string{
+			"releases", "db-job", "cc-bridge", "cells", "garden", "nsync",
+			"ducati-props", "connet-props", "acceptance-job", "acceptance-props",
+		}))
+
+		Expect(transformer.UseBackend("flannel")).To(Succeed())
+		Expect(transformer.StepNames()).To(Equal([]string{
+			"releases", "db-job", "cc-bridge", "cells", "garden", "nsync", "flannel-props",
+		}))
+	})
+
+	It("runs only the selected steps", func() {
+		transformer = ducatify.New(ducatify.WithOnlySteps("ducati-props", "connet-props"))
+
+		transformed, err := transform()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transformed["releases"]).To(BeEmpty())
+		Expect(transformed["jobs"]).To(HaveLen(3))
+		Expect(transformed["properties"]).To(HaveKey("ducati"))
+		Expect(transformed["properties"]).To(HaveKey("connet"))
+		Expect(transformed["properties"]).NotTo(HaveKey("acceptance-with-cf"))
+	})
+
+	It("leaves out skipped steps", func() {
+		transformer = ducatify.New(ducatify.WithSkipSteps("acceptance-job", "acceptance-props", "cc-bridge"))
+
+		transformed, err := transform()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transformed["jobs"]).To(HaveLen(4))
+		Expect(transformed["properties"]).To(HaveKey("ducati"))
+		Expect(transformed["properties"]).NotTo(HaveKey("acceptance-with-cf"))
+	})
+
+	It("rejects a step whose requirement is skipped and missing from the manifest", func() {
+		transformer = ducatify.New(ducatify.WithSkipSteps("releases"))
+
+		_, err := transform()
+		Expect(err).To(MatchError("step db-job needs step releases, which is not selected and has not been applied to the manifest"))
+	})
+
+	It("accepts a skipped requirement that the manifest already has", func() {
+		transformed, err := transform()
+		Expect(err).NotTo(HaveOccurred())
+
+		manifest = transformed
+		transformer = ducatify.New(ducatify.WithOnlySteps("cells", "nsync"))
+		_, err = transform()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("rejects cells without garden on a vanilla manifest", func() {
+		transformer = ducatify.New(ducatify.WithOnlySteps("releases", "cells"))
+
+		_, err := transform()
+		Expect(err).To(MatchError(ContainSubstring("step cells needs step garden")))
+	})
+
+	It("rejects unknown steps", func() {
+		transformer = ducatify.New(ducatify.WithOnlySteps("releases", "nope"))
+		Expect(transformer.Check()).To(MatchError(ContainSubstring(`unknown step "nope", expected one of [releases db-job`)))
+	})
+
+	It("rejects combining only and skip", func() {
+		transformer = ducatify.New(ducatify.WithOnlySteps("releases"), ducatify.WithSkipSteps("cells"))
+		Expect(transformer.Check()).To(MatchError("only_steps and skip_steps cannot be combined"))
+	})
+
+	It("rejects a selection for a rollout", func() {
+		transformer = ducatify.New(ducatify.WithOnlySteps("releases"))
+		_, err := transformer.Rollout(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).To(MatchError(ContainSubstring("cannot be combined with only_steps or skip_steps")))
+	})
+})
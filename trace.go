@@ -0,0 +1,137 @@
+package ducatify
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// StepHooks are called as Transform runs its steps, with the step's name,
+// e.g. "db-job" or "garden". Any of them may be nil.
+type StepHooks struct {
+	// Started is called before a step runs.
+	Started func(step string)
+
+	// Finished is called after a step succeeded, with the number of values
+	// it added, removed or replaced in the manifest. A new map or list
+	// counts as a single change.
+	Finished func(step string, changes int)
+
+	// Failed is called with the error of a step that failed. No further
+	// steps run.
+	Failed func(step string, err error)
+}
+
+func (h StepHooks) active() bool {
+	return h.Started != nil || h.Finished != nil || h.Failed != nil
+}
+
+func (t *Transformer) logf(format string, args ...interface{}) {
+	if t.Log != nil {
+		t.Log(fmt.Sprintf(format, args...))
+	}
+}
+
+// runStep runs s, reporting it to the Transformer's Log and Hooks.
+func (t *Transformer) runStep(s step, in stepInput) error {
+	if t.Log == nil && !t.Hooks.active() {
+		return s.run(t, in)
+	}
+
+	t.logf("%s: %s", s.name, s.context)
+	if t.Hooks.Started != nil {
+		t.Hooks.Started(s.name)
+	}
+	before := deepCopy(in.manifest.Map())
+
+	err := s.run(t, in)
+	if err != nil {
+		t.logf("%s: failed: %s", s.name, err)
+		if t.Hooks.Failed != nil {
+			t.Hooks.Failed(s.name, err)
+		}
+		return err
+	}
+
+	changes := countChanges(before, in.manifest.Map())
+	t.logf("%s: done, %d changes", s.name, changes)
+	if t.Hooks.Finished != nil {
+		t.Hooks.Finished(s.name, changes)
+	}
+	return nil
+}
+
+// countChanges counts the values that differ between two documents. Lists
+// of named items, such as jobs, are compared by name and other lists by
+// index.
+func countChanges(before, after interface{}) int {
+	switch b := before.(type) {
+	case map[interface{}]interface{}:
+		a, ok := after.(map[interface{}]interface{})
+		if !ok {
+			return 1
+		}
+		changes := 0
+		for key, val := range b {
+			if afterVal, ok := a[key]; ok {
+				changes += countChanges(val, afterVal)
+			} else {
+				changes++
+			}
+		}
+		for key := range a {
+			if _, ok := b[key]; !ok {
+				changes++
+			}
+		}
+		return changes
+
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok {
+			return 1
+		}
+		beforeNamed, okBefore := namedItems(b)
+		afterNamed, okAfter := namedItems(a)
+		if okBefore && okAfter {
+			return countChanges(beforeNamed, afterNamed)
+		}
+		changes := 0
+		for i := range b {
+			if i < len(a) {
+				changes += countChanges(b[i], a[i])
+			} else {
+				changes++
+			}
+		}
+		if len(a) > len(b) {
+			changes += len(a) - len(b)
+		}
+		return changes
+	}
+
+	if reflect.DeepEqual(normalizeValue(before), normalizeValue(after)) {
+		return 0
+	}
+	return 1
+}
+
+// namedItems maps the items of list by their name, if they all have a
+// distinct one.
+func namedItems(list []interface{}) (map[interface{}]interface{}, bool) {
+	named := map[interface{}]interface{}{}
+	for _, item := range list {
+		m, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok {
+			return nil, false
+		}
+		if _, dup := named[name]; dup {
+			return nil, false
+		}
+		named[name] = item
+	}
+	return named, true
+}
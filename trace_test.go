@@ -0,0 +1,138 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/candiedyaml"
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracing steps", func() {
+	var (
+		manifest    map[interface{}]interface{}
+		transformer *ducatify.Transformer
+		events      []string
+		finished    map[string]int
+		failed      map[string]error
+	)
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		manifest = map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cc_bridge_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z2", "templates": []interface{}{}},
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+
+		events = []string{}
+		finished = map[string]int{}
+		failed = map[string]error{}
+		transformer.Hooks = ducatify.StepHooks{
+			Started: func(step string) {
+				events = append(events, "started "+step)
+			},
+			Finished: func(step string, changes int) {
+				events = append(events, "finished "+step)
+				finished[step] = changes
+			},
+			Failed: func(step string, err error) {
+				events = append(events, "failed "+step)
+				failed[step] = err
+			},
+		}
+	})
+
+	transform := func() error {
+		_, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		return err
+	}
+
+	It("reports every step as it starts and finishes", func() {
+		Expect(transform()).To(Succeed())
+
+		Expect(events).To(Equal([]string{
+			"started releases", "finished releases",
+			"started db-job", "finished db-job",
+			"started cc-bridge", "finished cc-bridge",
+			"started cells", "finished cells",
+			"started garden", "finished garden",
+			"started nsync", "finished nsync",
+			"started ducati-props", "finished ducati-props",
+			"started connet-props", "finished connet-props",
+			"started acceptance-job", "finished acceptance-job",
+			"started acceptance-props", "finished acceptance-props",
+		}))
+	})
+
+	It("counts the changes each step makes", func() {
+		Expect(transform()).To(Succeed())
+
+		Expect(finished).To(HaveKeyWithValue("releases", 1))
+		Expect(finished).To(HaveKeyWithValue("db-job", 1))
+		Expect(finished).To(HaveKeyWithValue("cells", 2))
+		Expect(finished).To(HaveKeyWithValue("nsync", 1))
+		Expect(finished).To(HaveKeyWithValue("ducati-props", 1))
+	})
+
+	It("counts no changes when a step is applied again to a manifest read back from yaml", func() {
+		transformed, err := transformer.Transform(manifest, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+		bytes, err := candiedyaml.Marshal(transformed)
+		Expect(err).NotTo(HaveOccurred())
+		manifest = map[interface{}]interface{}{}
+		Expect(candiedyaml.Unmarshal(bytes, &manifest)).To(Succeed())
+
+		transformer.OnlySteps = []string{"ducati-props", "connet-props"}
+		Expect(transform()).To(Succeed())
+
+		Expect(finished).To(HaveKeyWithValue("ducati-props", 0))
+		Expect(finished).To(HaveKeyWithValue("connet-props", 0))
+	})
+
+	It("reports the step that failed and stops", func() {
+		manifest["jobs"] = []interface{}{
+			map[interface{}]interface{}{"name": "cell_z1", "templates": []interface{}{}},
+		}
+
+		Expect(transform()).NotTo(Succeed())
+		Expect(events).To(Equal([]string{
+			"started releases", "finished releases",
+			"started db-job", "failed db-job",
+		}))
+		Expect(failed["db-job"]).To(MatchError(ContainSubstring("database_z1 job not found")))
+	})
+
+	It("writes a trace to the log", func() {
+		lines := []string{}
+		transformer.Log = func(line string) {
+			lines = append(lines, line)
+		}
+
+		Expect(transform()).To(Succeed())
+		Expect(lines).To(ContainElement("releases: updating releases"))
+		Expect(lines).To(ContainElement("releases: done, 1 changes"))
+	})
+
+	It("logs failures", func() {
+		lines := []string{}
+		transformer.Log = func(line string) {
+			lines = append(lines, line)
+		}
+		manifest["jobs"] = []interface{}{}
+
+		Expect(transform()).NotTo(Succeed())
+		Expect(lines).To(ContainElement(ContainSubstring("db-job: failed: database_z1 job not found")))
+	})
+})
@@ -0,0 +1,188 @@
+package ducatify
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// VerifyResult is one item of the checklist that Verify produces.
+type VerifyResult struct {
+	Check  string
+	Passed bool
+
+	// Detail explains why the check failed.
+	Detail string
+}
+
+func passed(check string) VerifyResult {
+	return VerifyResult{Check: check, Passed: true}
+}
+
+func failed(check, format string, args ...interface{}) VerifyResult {
+	return VerifyResult{Check: check, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Verify checks that a deployed manifest still has everything Transform
+// would add with the Transformer's settings. It only returns an error when
+// the manifest cannot be checked at all; drift shows up as failed results.
+func (t *Transformer) Verify(manifest map[interface{}]interface{}) ([]VerifyResult, error) {
+	m, err := DecodeManifest(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("decoding manifest: %s", err)
+	}
+	return t.VerifyManifest(m)
+}
+
+// VerifyManifest is Verify for a decoded Manifest.
+func (t *Transformer) VerifyManifest(manifest *Manifest) ([]VerifyResult, error) {
+	backend, err := t.backend()
+	if err != nil {
+		return nil, err
+	}
+	cells, _, err := t.ducatiCells(manifest)
+	if err != nil {
+		return nil, err
+	}
+	dnsServers, err := t.gardenDNSServers(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []VerifyResult{}
+	for _, release := range backend.Releases(t) {
+		check := "release " + release.Name
+		if manifest.hasRelease(release.Name) {
+			results = append(results, passed(check))
+		} else {
+			results = append(results, failed(check, "missing"))
+		}
+	}
+
+	if len(cells) == 0 {
+		results = append(results, failed("cells", "no cell has %s enabled", t.Backend))
+	}
+	for _, job := range cells {
+		results = append(results, verifyTemplates(job, backend.CellTemplates(t, job.Name)))
+	}
+
+	defaults := gardenSettings{
+		networkPlugin: t.GardenNetworkPlugin,
+		extraArgs:     t.GardenNetworkPluginExtraArgs,
+		sharedMounts:  t.GardenSharedMounts,
+		dnsServers:    dnsServers,
+	}
+	for _, job := range cells {
+		expected := t.GardenOverrides[job.Name].apply(defaults)
+		results = append(results, verifyGarden(manifest, job, expected))
+	}
+
+	networkID, err := lookupProperty(manifest.Properties, "diego.nsync.network_id")
+	if err != nil {
+		return nil, err
+	}
+	if networkID == t.NsyncNetworkID {
+		results = append(results, passed("nsync network_id"))
+	} else {
+		results = append(results, failed("nsync network_id", "%s, expected %q", describeSetting(networkID), t.NsyncNetworkID))
+	}
+
+	backendResults, err := backend.Verify(t, manifest)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, backendResults...), nil
+}
+
+// verifyTemplates checks that job has each of templates.
+func verifyTemplates(job *Job, templates []*Template) VerifyResult {
+	names := []string{}
+	missing := []string{}
+	for _, template := range templates {
+		names = append(names, template.Name)
+		if !job.HasTemplate(template.Name) {
+			missing = append(missing, template.Name)
+		}
+	}
+
+	check := fmt.Sprintf("%s has %s", job.Name, strings.Join(names, " and "))
+	if len(missing) > 0 {
+		return failed(check, "missing %s", strings.Join(missing, ", "))
+	}
+	return passed(check)
+}
+
+// verifyGarden checks the garden properties that a cell job sees, which
+// are the global ones updated by the job's own.
+func verifyGarden(manifest *Manifest, job *Job, expected gardenSettings) VerifyResult {
+	check := "garden on " + job.Name
+	garden := map[interface{}]interface{}{}
+	for _, props := range []map[interface{}]interface{}{manifest.Properties, job.Properties} {
+		block, err := lookupProperty(props, "garden")
+		if err != nil {
+			return failed(check, "%s", err)
+		}
+		if blockMap, ok := block.(map[interface{}]interface{}); ok {
+			for key, val := range blockMap {
+				garden[key] = val
+			}
+		}
+	}
+
+	problems := []string{}
+	if expected.networkPlugin != "" && garden["network_plugin"] != expected.networkPlugin {
+		problems = append(problems, fmt.Sprintf("network_plugin %s, expected %q", describeSetting(garden["network_plugin"]), expected.networkPlugin))
+	}
+	for _, list := range []struct {
+		key      string
+		expected []string
+	}{
+		{"network_plugin_extra_args", expected.extraArgs},
+		{"shared_mounts", expected.sharedMounts},
+		{"dns_servers", expected.dnsServers},
+	} {
+		actual, err := mergeStringList(garden[list.key], nil)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", list.key, err))
+			continue
+		}
+		for _, val := range list.expected {
+			if !containsString(actual, val) {
+				problems = append(problems, fmt.Sprintf("%s lacks %q", list.key, val))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return failed(check, "%s", strings.Join(problems, "; "))
+	}
+	return passed(check)
+}
+
+func describeSetting(val interface{}) string {
+	if val == nil {
+		return "is not set"
+	}
+	return fmt.Sprintf("is %q", fmt.Sprint(val))
+}
+
+// verifySameKeys checks that two property maps agree on keys, naming the
+// keys that differ but not their values, which may be secret.
+func verifySameKeys(check string, a, b interface{}, keys ...string) VerifyResult {
+	aMap, aOK := a.(map[interface{}]interface{})
+	bMap, bOK := b.(map[interface{}]interface{})
+	if !aOK || !bOK {
+		return failed(check, "missing")
+	}
+
+	differ := []string{}
+	for _, key := range keys {
+		if !reflect.DeepEqual(aMap[key], bMap[key]) {
+			differ = append(differ, key)
+		}
+	}
+	if len(differ) > 0 {
+		return failed(check, "%s differ", strings.Join(differ, ", "))
+	}
+	return passed(check)
+}
@@ -0,0 +1,164 @@
+package ducatify_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducatify"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verify", func() {
+	var (
+		transformer *ducatify.Transformer
+		manifest    map[interface{}]interface{}
+	)
+
+	BeforeEach(func() {
+		transformer = ducatify.New()
+		transformer.DeriveGardenDNSServers = false
+
+		vanilla := map[interface{}]interface{}{
+			"releases": []interface{}{},
+			"jobs": []interface{}{
+				map[interface{}]interface{}{"name": "database_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cc_bridge_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z1", "templates": []interface{}{}},
+				map[interface{}]interface{}{"name": "cell_z2", "templates": []interface{}{}},
+			},
+			"properties": map[interface{}]interface{}{
+				"garden": map[interface{}]interface{}{},
+				"diego": map[interface{}]interface{}{
+					"nsync":         map[interface{}]interface{}{},
+					"route_emitter": map[interface{}]interface{}{"nats": "some-nats"},
+				},
+			},
+		}
+
+		var err error
+		manifest, err = transformer.Transform(vanilla, map[interface{}]interface{}{}, "some.system.domain")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	verify := func() []ducatify.VerifyResult {
+		results, err := transformer.Verify(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		return results
+	}
+
+	failures := func(results []ducatify.VerifyResult) []ducatify.VerifyResult {
+		failed := []ducatify.VerifyResult{}
+		for _, result := range results {
+			if !result.Passed {
+				failed = append(failed, result)
+			}
+		}
+		return failed
+	}
+
+	props := func() map[interface{}]interface{} {
+		return manifest["properties"].(map[interface{}]interface{})
+	}
+
+	job := func(name string) map[interface{}]interface{} {
+		for _, j := range manifest["jobs"].([]interface{}) {
+			if j.(map[interface{}]interface{})["name"] == name {
+				return j.(map[interface{}]interface{})
+			}
+		}
+		return nil
+	}
+
+	It("passes every check on a freshly transformed manifest", func() {
+		results := verify()
+		Expect(failures(results)).To(BeEmpty())
+
+		checks := []string{}
+		for _, result := range results {
+			checks = append(checks, result.Check)
+		}
+		Expect(checks).To(Equal([]string{
+			"release ducati",
+			"cell_z1 has ducati",
+			"cell_z2 has ducati",
+			"garden on cell_z1",
+			"garden on cell_z2",
+			"nsync network_id",
+			"ducati_db has postgres",
+			"cc_bridge_z1 has connet and route_registrar",
+			"ducati and connet database credentials agree",
+		}))
+	})
+
+	It("reports a cell without the ducati template", func() {
+		job("cell_z2")["templates"] = []interface{}{}
+
+		Expect(failures(verify())).To(Equal([]ducatify.VerifyResult{
+			{Check: "cell_z2 has ducati", Detail: "missing ducati"},
+		}))
+	})
+
+	It("reports a cc_bridge without connet", func() {
+		job("cc_bridge_z1")["templates"] = []interface{}{
+			map[interface{}]interface{}{"name": "route_registrar", "release": "cf"},
+		}
+
+		Expect(failures(verify())).To(Equal([]ducatify.VerifyResult{
+			{Check: "cc_bridge_z1 has connet and route_registrar", Detail: "missing connet"},
+		}))
+	})
+
+	It("reports drifted garden and nsync properties", func() {
+		garden := props()["garden"].(map[interface{}]interface{})
+		garden["network_plugin"] = "/some/other/plugin"
+		delete(garden, "shared_mounts")
+		props()["diego"].(map[interface{}]interface{})["nsync"] = map[interface{}]interface{}{}
+
+		Expect(failures(verify())).To(ConsistOf(
+			ducatify.VerifyResult{
+				Check: "garden on cell_z1",
+				Detail: `network_plugin is "/some/other/plugin", expected "/var/vcap/packages/ducati/bin/guardian-cni-adapter"; ` +
+					`shared_mounts lacks "/var/vcap/data/ducati/container-netns"`,
+			},
+			ducatify.VerifyResult{
+				Check: "garden on cell_z2",
+				Detail: `network_plugin is "/some/other/plugin", expected "/var/vcap/packages/ducati/bin/guardian-cni-adapter"; ` +
+					`shared_mounts lacks "/var/vcap/data/ducati/container-netns"`,
+			},
+			ducatify.VerifyResult{Check: "nsync network_id", Detail: `is not set, expected "ducati-overlay"`},
+		))
+	})
+
+	It("takes job-level garden properties into account", func() {
+		transformer.GardenOverrides = map[string]ducatify.GardenOverride{
+			"cell_z2": {NetworkPlugin: "/some/other/plugin"},
+		}
+		job("cell_z2")["properties"] = map[interface{}]interface{}{
+			"garden": map[interface{}]interface{}{"network_plugin": "/some/other/plugin"},
+		}
+
+		Expect(failures(verify())).To(BeEmpty())
+	})
+
+	It("reports a missing ducati_db job", func() {
+		jobs := []interface{}{}
+		for _, j := range manifest["jobs"].([]interface{}) {
+			if j.(map[interface{}]interface{})["name"] != "ducati_db" {
+				jobs = append(jobs, j)
+			}
+		}
+		manifest["jobs"] = jobs
+
+		Expect(failures(verify())).To(Equal([]ducatify.VerifyResult{
+			{Check: "ducati_db job", Detail: "missing"},
+		}))
+	})
+
+	It("reports database credentials that disagree without showing them", func() {
+		connetDB := props()["connet"].(map[interface{}]interface{})["daemon"].(map[interface{}]interface{})["database"]
+		connetDB.(map[interface{}]interface{})["password"] = "rotated-password"
+
+		Expect(failures(verify())).To(Equal([]ducatify.VerifyResult{
+			{Check: "ducati and connet database credentials agree", Detail: "password differ"},
+		}))
+	})
+})
@@ -1,31 +1,49 @@
 package ducatify
 
-import "fmt"
-
-func getElement(el interface{}, key string) (interface{}, error) {
-	if um, ok := el.(map[interface{}]interface{}); ok {
-		if v, ok := um[key]; ok {
-			return v, nil
+func deepCopy(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		copied := make(map[interface{}]interface{}, len(v))
+		for key, el := range v {
+			copied[key] = deepCopy(el)
 		}
-		return nil, fmt.Errorf("map missing key %s", key)
-	}
-
-	return nil, fmt.Errorf("unable to unpack %T", el)
-}
-
-func setElement(el interface{}, key string, val interface{}) error {
-	if um, ok := el.(map[interface{}]interface{}); ok {
-		um[key] = val
-		return nil
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, el := range v {
+			copied[i] = deepCopy(el)
+		}
+		return copied
+	case []string:
+		return append([]string{}, v...)
 	}
-	return fmt.Errorf("unable to unpack %T", el)
+	return val
 }
 
-func appendToSlice(toModify interface{}, toAppend interface{}) ([]interface{}, error) {
-	asSlice, ok := toModify.([]interface{})
-	if !ok {
-		panic("input type not slice")
+// integerValue returns val as an int64 if it holds any integer type. yaml
+// packages differ in which type they decode integers to.
+func integerValue(val interface{}) (int64, bool) {
+	switch i := val.(type) {
+	case int:
+		return int64(i), true
+	case int8:
+		return int64(i), true
+	case int16:
+		return int64(i), true
+	case int32:
+		return int64(i), true
+	case int64:
+		return i, true
+	case uint:
+		return int64(i), true
+	case uint8:
+		return int64(i), true
+	case uint16:
+		return int64(i), true
+	case uint32:
+		return int64(i), true
+	case uint64:
+		return int64(i), true
 	}
-	asSlice = append(asSlice, toAppend)
-	return asSlice, nil
+	return 0, false
 }